@@ -0,0 +1,141 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// 导入压缩包的安全限制，防止压缩炸弹
+const (
+	maxImportFiles            = 10000
+	maxImportUncompressedSize = 1 << 30 // 1GB
+)
+
+// ArchiveEntryIssue 压缩包中不安全的条目
+type ArchiveEntryIssue struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ArchiveValidationError 压缩包结构校验失败，列出所有有问题的条目
+type ArchiveValidationError struct {
+	Issues []ArchiveEntryIssue
+}
+
+func (e *ArchiveValidationError) Error() string {
+	names := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		names = append(names, fmt.Sprintf("%s(%s)", issue.Name, issue.Reason))
+	}
+	return "压缩包包含不安全的条目: " + strings.Join(names, ", ")
+}
+
+// normalizeArchiveName 统一使用 / 作为路径分隔符
+func normalizeArchiveName(name string) string {
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
+// checkArchiveEntryName 检查条目名称是否会逃逸出解压目录，返回问题原因，安全时返回空字符串
+func checkArchiveEntryName(name string) string {
+	name = normalizeArchiveName(name)
+
+	if name == "" {
+		return "条目名称为空"
+	}
+	if strings.HasPrefix(name, "/") {
+		return "绝对路径"
+	}
+	// Windows 盘符路径，例如 C:/Windows
+	if len(name) >= 2 && name[1] == ':' {
+		return "绝对路径"
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "路径穿越"
+		}
+	}
+	if strings.ContainsRune(name, 0) {
+		return "名称包含非法字符"
+	}
+	return ""
+}
+
+// validateZipEntries 在解压前检查所有条目：拒绝路径穿越、绝对路径、符号链接和特殊文件，
+// 并限制文件数量和解压后的总大小
+func validateZipEntries(files []*zip.File) error {
+	var issues []ArchiveEntryIssue
+	var totalSize uint64
+	fileCount := 0
+
+	for _, file := range files {
+		if reason := checkArchiveEntryName(file.Name); reason != "" {
+			issues = append(issues, ArchiveEntryIssue{Name: file.Name, Reason: reason})
+			continue
+		}
+
+		mode := file.Mode()
+		if mode&os.ModeSymlink != 0 {
+			issues = append(issues, ArchiveEntryIssue{Name: file.Name, Reason: "符号链接"})
+			continue
+		}
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() {
+			issues = append(issues, ArchiveEntryIssue{Name: file.Name, Reason: "不支持的文件类型"})
+			continue
+		}
+
+		fileCount++
+		totalSize += file.UncompressedSize64
+	}
+
+	if fileCount > maxImportFiles {
+		issues = append(issues, ArchiveEntryIssue{
+			Name:   "*",
+			Reason: fmt.Sprintf("文件数量 %d 超过限制 %d", fileCount, maxImportFiles),
+		})
+	}
+	if totalSize > maxImportUncompressedSize {
+		issues = append(issues, ArchiveEntryIssue{
+			Name:   "*",
+			Reason: fmt.Sprintf("解压后大小 %d 字节超过限制 %d 字节", totalSize, maxImportUncompressedSize),
+		})
+	}
+
+	if len(issues) > 0 {
+		return &ArchiveValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// extractBudget 记录解压过程中剩余的字节配额，防止条目头部声明的大小与实际内容不符
+type extractBudget struct {
+	remaining int64
+}
+
+func newExtractBudget() *extractBudget {
+	return &extractBudget{remaining: maxImportUncompressedSize}
+}
+
+// copy 在配额内复制内容，超出配额时返回错误
+func (b *extractBudget) copy(dst io.Writer, src io.Reader) error {
+	n, err := io.Copy(dst, io.LimitReader(src, b.remaining+1))
+	b.remaining -= n
+	if err != nil {
+		return err
+	}
+	if b.remaining < 0 {
+		return fmt.Errorf("解压后大小超过限制 %d 字节", int64(maxImportUncompressedSize))
+	}
+	return nil
+}
+
+// cleanArchiveName 返回规范化后的相对路径
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+normalizeArchiveName(name)), "/")
+}
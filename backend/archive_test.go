@@ -0,0 +1,238 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testZipEntry 测试压缩包中的一个条目，size 不为 0 时只写入声明的大小而不写内容
+type testZipEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+	size    uint64
+}
+
+// buildTestZip 在内存中构造压缩包，可以写入 archive/zip 正常情况下不会生成的条目名称和声明大小
+func buildTestZip(t *testing.T, entries []testZipEntry) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Store}
+		if entry.mode != 0 {
+			header.SetMode(entry.mode)
+		}
+
+		if entry.size > 0 {
+			header.CompressedSize64 = 0
+			header.UncompressedSize64 = entry.size
+			if _, err := writer.CreateRaw(header); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+// issueNames 返回校验错误中列出的条目名称
+func issueNames(t *testing.T, err error) []string {
+	t.Helper()
+	var validationErr *ArchiveValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error = %v, want *ArchiveValidationError", err)
+	}
+	names := make([]string, 0, len(validationErr.Issues))
+	for _, issue := range validationErr.Issues {
+		names = append(names, issue.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCheckArchiveEntryName(t *testing.T) {
+	tests := []struct {
+		name string
+		safe bool
+	}{
+		{"Farm_1/Farm_1", true},
+		{"Farm_1/", true},
+		{"Farm_1/..hidden", true},
+		{"Farm_1/a..b", true},
+		{"../evil", false},
+		{"Farm_1/../../evil", false},
+		{"Farm_1/..", false},
+		{`..\evil`, false},
+		{`Farm_1\..\..\evil`, false},
+		{"/etc/passwd", false},
+		{`\evil`, false},
+		{`C:\Windows\evil`, false},
+		{"C:/Windows/evil", false},
+		{"c:evil", false},
+		{"", false},
+		{"Farm_1/a\x00b", false},
+	}
+	for _, tt := range tests {
+		reason := checkArchiveEntryName(tt.name)
+		if (reason == "") != tt.safe {
+			t.Errorf("checkArchiveEntryName(%q) = %q, want safe=%v", tt.name, reason, tt.safe)
+		}
+	}
+}
+
+func TestValidateZipEntriesAcceptsSave(t *testing.T) {
+	reader := buildTestZip(t, []testZipEntry{
+		{name: "Farm_1/", mode: os.ModeDir | 0755},
+		{name: "Farm_1/Farm_1", content: "<SaveGame />"},
+		{name: "Farm_1/SaveGameInfo", content: "<Farmer />"},
+	})
+	if err := validateZipEntries(reader.File); err != nil {
+		t.Fatalf("validateZipEntries() = %v, want nil", err)
+	}
+}
+
+func TestValidateZipEntriesRejectsUnsafeEntries(t *testing.T) {
+	reader := buildTestZip(t, []testZipEntry{
+		{name: "Farm_1/Farm_1", content: "<SaveGame />"},
+		{name: "../evil", content: "x"},
+		{name: "Farm_1/../../evil2", content: "x"},
+		{name: "/etc/cron.d/evil", content: "x"},
+		{name: `C:\Windows\evil.dll`, content: "x"},
+		{name: `..\windows-evil`, content: "x"},
+		{name: "Farm_1/link", content: "/etc/passwd", mode: os.ModeSymlink | 0777},
+		{name: "Farm_1/fifo", mode: os.ModeNamedPipe | 0644},
+	})
+
+	err := validateZipEntries(reader.File)
+	if err == nil {
+		t.Fatal("validateZipEntries() = nil, want error")
+	}
+	want := []string{"../evil", "/etc/cron.d/evil", `C:\Windows\evil.dll`, "Farm_1/../../evil2", "Farm_1/fifo", "Farm_1/link", `..\windows-evil`}
+	sort.Strings(want)
+	if got := issueNames(t, err); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("offending entries = %q, want %q", got, want)
+	}
+	if strings.Contains(err.Error(), "Farm_1/Farm_1(") {
+		t.Errorf("error lists a safe entry: %v", err)
+	}
+}
+
+func TestValidateZipEntriesRejectsTooManyFiles(t *testing.T) {
+	entries := make([]testZipEntry, 0, maxImportFiles+1)
+	for i := 0; i <= maxImportFiles; i++ {
+		entries = append(entries, testZipEntry{name: fmt.Sprintf("Farm_1/f%05d", i)})
+	}
+	reader := buildTestZip(t, entries)
+
+	err := validateZipEntries(reader.File)
+	if err == nil {
+		t.Fatal("validateZipEntries() = nil, want error")
+	}
+	if got := issueNames(t, err); len(got) != 1 || got[0] != "*" || !strings.Contains(err.Error(), "文件数量") {
+		t.Errorf("error = %v, want a single file count issue", err)
+	}
+
+	// 恰好达到上限时允许
+	reader = buildTestZip(t, entries[:maxImportFiles])
+	if err := validateZipEntries(reader.File); err != nil {
+		t.Errorf("validateZipEntries() with %d files = %v, want nil", maxImportFiles, err)
+	}
+}
+
+func TestValidateZipEntriesRejectsOversizedArchive(t *testing.T) {
+	// 单个条目超过上限，以及多个条目合计超过上限
+	for _, entries := range [][]testZipEntry{
+		{{name: "Farm_1/Farm_1", size: maxImportUncompressedSize + 1}},
+		{
+			{name: "Farm_1/Farm_1", size: maxImportUncompressedSize / 2},
+			{name: "Farm_1/Farm_1_old", size: maxImportUncompressedSize/2 + 1},
+		},
+	} {
+		reader := buildTestZip(t, entries)
+		err := validateZipEntries(reader.File)
+		if err == nil {
+			t.Fatal("validateZipEntries() = nil, want error")
+		}
+		if got := issueNames(t, err); len(got) != 1 || got[0] != "*" || !strings.Contains(err.Error(), "解压后大小") {
+			t.Errorf("error = %v, want a single size issue", err)
+		}
+	}
+}
+
+func TestExtractBudget(t *testing.T) {
+	budget := &extractBudget{remaining: 10}
+	var out bytes.Buffer
+	if err := budget.copy(&out, strings.NewReader("12345")); err != nil {
+		t.Fatalf("copy within budget = %v", err)
+	}
+	if err := budget.copy(&out, strings.NewReader("67890")); err != nil {
+		t.Fatalf("copy up to budget = %v", err)
+	}
+	// 条目声明的大小可能是假的，实际内容超出配额时必须停止
+	if err := budget.copy(&out, strings.NewReader("x")); err == nil {
+		t.Fatal("copy over budget = nil, want error")
+	}
+	if out.Len() > 11 {
+		t.Errorf("copied %d bytes, want at most budget+1", out.Len())
+	}
+}
+
+func TestExtractFileStaysInTarget(t *testing.T) {
+	base := t.TempDir()
+	target := filepath.Join(base, "target")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 即使绕过了 validateZipEntries，extractFile 也只会写入目标目录内
+	reader := buildTestZip(t, []testZipEntry{
+		{name: "Farm_1/../../../escaped", content: "x"},
+		{name: `Farm_1\..\..\escaped2`, content: "x"},
+		{name: "/abs", content: "x"},
+	})
+	s := &SaveService{}
+	for _, file := range reader.File {
+		if err := s.extractFile(file, target, "Farm_1/", newExtractBudget()); err != nil {
+			t.Logf("extractFile(%q) = %v", file.Name, err)
+		}
+	}
+
+	for _, name := range []string{"escaped", "escaped2", "abs"} {
+		if _, err := os.Stat(filepath.Join(base, name)); err == nil {
+			t.Errorf("%s was written outside the target directory", name)
+		}
+	}
+	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !isWithinDir(target, path) {
+			t.Errorf("file written outside target: %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
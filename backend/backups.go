@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupsDir 备份文件的存放目录，新备份会再复制到配置的复制目标
+const backupsDir = "./backups"
+
+// CreateLibraryBackup 在后台把整个存档库打包到备份目录，返回任务信息
+func (s *SaveService) CreateLibraryBackup(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	saves, err := s.scanSaves(lib.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "扫描存档失败: " + err.Error(),
+		})
+		return
+	}
+	if len(saves) == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "存档库中没有存档",
+		})
+		return
+	}
+
+	exportedBy := requestUsername(c)
+	s.submitJob(c, "library_backup", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+		return s.backupLibrary(ctx, lib, saves, exportedBy, job)
+	})
+}
+
+// backupLibrary 把存档库中的所有存档写入一个备份文件，每个存档一个目录，格式与批量导出相同。
+// 先在临时目录中生成，完成后再写入备份目录并复制到复制目标。配置了加密时备份会被加密
+func (s *SaveService) backupLibrary(ctx context.Context, lib SaveLibrary, saves []SaveInfo, exportedBy string, job *jobEntry) (interface{}, error) {
+	secret, err := encryptionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	files := 0
+	var size int64
+	for _, save := range saves {
+		n, bytes := directoryStats(save.Path)
+		files += n
+		size += bytes
+	}
+	job.setTotal(files, size)
+
+	tempDir, err := newTempDir("backup_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	name := encryptedName(fmt.Sprintf("%s_library_%s.zip", filepath.Base(lib.Name), time.Now().Format("20060102_150405")), secret)
+	tempPath := filepath.Join(tempDir, name)
+
+	writer, closeFile, err := createArchiveFile(tempPath, secret)
+	if err != nil {
+		return nil, fmt.Errorf("创建备份文件失败: %v", err)
+	}
+
+	_, err = s.writeSavesZip(ctx, writer, saves, true, exportedBy, job)
+	if closeErr := closeFile(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.storeBackup(ctx, tempPath, name)
+	}
+	if err != nil {
+		s.addLog("backup", fmt.Sprintf("备份存档库失败: %s", lib.Name), false, err.Error())
+		return nil, err
+	}
+
+	s.addLog("backup", fmt.Sprintf("备份存档库: %s，%d 个存档", lib.Name, len(saves)), true, "")
+	return gin.H{
+		"backup":    name,
+		"count":     len(saves),
+		"encrypted": secret != nil,
+		"replicas":  s.backupCatalog()[name],
+	}, nil
+}
+
+// ListBackups 列出备份目录中的备份文件及其复制状态，新的在前。
+// 使用 target 参数时列出该复制目标中的备份
+func (s *SaveService) ListBackups(c *gin.Context) {
+	var storage BackupStorage = primaryBackupStorage()
+	targetName := c.Query("target")
+	if targetName != "" {
+		target, ok := s.backupTarget(targetName)
+		if !ok {
+			c.JSON(http.StatusNotFound, APIResponse{
+				Success: false,
+				Error:   "复制目标不存在",
+			})
+			return
+		}
+		storage = target
+	}
+
+	stored, err := storage.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "读取备份列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	var catalog map[string][]BackupReplica
+	if targetName == "" {
+		catalog = s.backupCatalog()
+	}
+	backups := make([]BackupInfo, 0, len(stored))
+	for _, backup := range stored {
+		backups = append(backups, BackupInfo{
+			Name:      backup.Name,
+			Size:      backup.Size,
+			Encrypted: strings.HasSuffix(backup.Name, encryptedSuffix),
+			CreatedAt: backup.ModTime,
+			Replicas:  catalog[backup.Name],
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    backups,
+	})
+}
+
+// isBackupFileName 判断是否是备份文件，未完成的备份不会被列出
+func isBackupFileName(name string) bool {
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".zip"+encryptedSuffix)
+}
+
+// RestoreBackup 把备份导入到存档库，加密的备份会被自动解密。默认覆盖同名存档并在覆盖前再备份一次，
+// 请求体可以使用与上传导入相同的参数。使用 target 参数时从该复制目标下载备份后恢复
+func (s *SaveService) RestoreBackup(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+	var target BackupStorage
+	if targetName := c.Query("target"); targetName != "" {
+		if target, ok = s.backupTarget(targetName); !ok || !isSafeBackupName(name) {
+			c.JSON(http.StatusNotFound, APIResponse{
+				Success: false,
+				Error:   "复制目标或备份不存在",
+			})
+			return
+		}
+	} else if _, ok := localBackupName(c); !ok {
+		return
+	}
+
+	req := ImportRequest{OverwriteExisting: true, BackupExisting: true}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	restore := func(ctx context.Context, progress progressReporter) (*ImportResult, error) {
+		if target == nil {
+			return s.importLocalArchive(ctx, lib.Path, filepath.Join(backupsDir, name), req, progress)
+		}
+
+		tempDir, err := newTempDir("restore_")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tempDir)
+
+		backupPath, err := downloadBackup(ctx, target, name, tempDir)
+		if err != nil {
+			return nil, err
+		}
+		return s.importLocalArchive(ctx, lib.Path, backupPath, req, progress)
+	}
+
+	if wantsAsync(c) {
+		s.submitJob(c, "restore", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+			return s.runImportJob(name, func() (*ImportResult, error) {
+				return restore(ctx, job)
+			})
+		})
+		return
+	}
+
+	result, err := restore(c.Request.Context(), noProgress{})
+	if err != nil {
+		s.addLog("restore", fmt.Sprintf("恢复备份失败: %s", name), false, err.Error())
+		if errors.Is(err, errBackupNotFound) {
+			c.JSON(http.StatusNotFound, APIResponse{
+				Success: false,
+				Error:   "备份不存在",
+			})
+			return
+		}
+		s.respondConvertError(c, err)
+		return
+	}
+
+	s.logImportResult(result, name)
+	s.respondImportResult(c, result)
+}
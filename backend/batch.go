@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// add 记录一个存档的结果
+func (r *BatchResult) add(item BatchItemResult) {
+	if item.Status == BatchStatusExported || item.Status == BatchStatusDeleted {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Results = append(r.Results, item)
+}
+
+// logBatchResult 为批量操作中的每个存档分别记录日志
+func (s *SaveService) logBatchResult(action, message string, result *BatchResult) {
+	for _, item := range result.Results {
+		name := item.Name
+		if name == "" {
+			name = item.ID
+		}
+
+		success := item.Status == BatchStatusExported || item.Status == BatchStatusDeleted
+		details := item.Error
+		if success && item.Warning != "" {
+			details = item.Warning
+		}
+		s.addLog(action, fmt.Sprintf("%s: %s", message, name), success, details)
+	}
+}
+
+// respondBatchResult 返回批量操作的结果，没有任何存档处理成功时返回 400
+func (s *SaveService) respondBatchResult(c *gin.Context, result *BatchResult, verb string) {
+	if result.Succeeded == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("没有%s任何存档: %s", verb, result.Results[0].Error),
+			Data:    result,
+		})
+		return
+	}
+
+	message := fmt.Sprintf("成功%s %d 个存档", verb, result.Succeeded)
+	if result.Failed > 0 {
+		message = fmt.Sprintf("%s %d 个存档，%d 个失败", verb, result.Succeeded, result.Failed)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
+
+// batchDelete 逐个备份并删除存档，返回每个存档的结果。取消后剩余的存档记为跳过
+func (s *SaveService) batchDelete(ctx context.Context, rootPath string, saveIDs []string, force bool, progress progressReporter) (*BatchResult, error) {
+	progress.setTotal(len(saveIDs), 0)
+
+	result := &BatchResult{Results: make([]BatchItemResult, 0, len(saveIDs))}
+	for _, id := range saveIDs {
+		if err := ctx.Err(); err != nil {
+			result.add(BatchItemResult{ID: id, Status: BatchStatusSkipped, Error: "任务已取消"})
+			continue
+		}
+
+		result.add(s.deleteWithBackup(rootPath, id, force))
+		progress.advance(1, 0)
+	}
+
+	s.logBatchResult("batch_delete", "批量删除存档", result)
+	return result, ctx.Err()
+}
+
+// deleteWithBackup 备份后删除单个存档。备份失败时不删除，除非 force 为 true
+func (s *SaveService) deleteWithBackup(rootPath, id string, force bool) BatchItemResult {
+	item := BatchItemResult{ID: id}
+
+	save, err := s.getSaveByID(rootPath, id)
+	if err != nil {
+		item.Status = BatchStatusNotFound
+		item.Error = "存档不存在"
+		return item
+	}
+	item.Name = save.Name
+
+	if !s.isAllowedPath(save.Path) {
+		item.Status = BatchStatusFailed
+		item.Error = "存档路径不在允许的目录范围内"
+		return item
+	}
+
+	// 备份存档
+	backupPath, err := s.createBackup(save.Path, fmt.Sprintf("%s_%d.zip", save.Name, time.Now().Unix()))
+	if err != nil {
+		if !force {
+			item.Status = BatchStatusFailed
+			item.Error = "备份失败，未删除: " + err.Error()
+			return item
+		}
+		item.Warning = "备份失败，已强制删除: " + err.Error()
+	} else {
+		item.BackupPath = backupPath
+	}
+
+	// 删除存档
+	if err := os.RemoveAll(save.Path); err != nil {
+		item.Status = BatchStatusFailed
+		item.Error = "删除存档失败: " + err.Error()
+		return item
+	}
+
+	item.Status = BatchStatusDeleted
+	return item
+}
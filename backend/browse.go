@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BrowseDirectories 浏览允许的根目录下的子目录，用于在界面中选择存档路径
+func (s *SaveService) BrowseDirectories(c *gin.Context) {
+	path := c.Query("path")
+
+	// 未指定路径时列出所有允许的根目录
+	if path == "" {
+		entries := make([]BrowseEntry, 0)
+		for _, root := range s.allowedRoots() {
+			if !s.isValidPath(root) {
+				continue
+			}
+			entries = append(entries, s.describeDirectory(root, root))
+		}
+
+		c.JSON(http.StatusOK, APIResponse{
+			Success: true,
+			Data: BrowseResult{
+				IsRoot:  true,
+				Entries: entries,
+			},
+		})
+		return
+	}
+
+	path = filepath.Clean(path)
+	if !s.isValidPath(path) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "路径无效或不在允许的目录范围内",
+		})
+		return
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "读取目录失败: " + err.Error(),
+		})
+		return
+	}
+
+	entries := make([]BrowseEntry, 0)
+	for _, entry := range dirEntries {
+		// 只列出普通子目录，跳过隐藏目录和符号链接
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		entries = append(entries, s.describeDirectory(entry.Name(), filepath.Join(path, entry.Name())))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	result := BrowseResult{
+		Path:      path,
+		SaveCount: s.countValidSaves(path),
+		Entries:   entries,
+	}
+	result.IsSaveFolder = result.SaveCount > 0
+
+	// 上级目录仍在允许范围内时才提供返回上级的路径
+	if parent := filepath.Dir(path); parent != path && s.isValidPath(parent) {
+		result.Parent = parent
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// describeDirectory 标注目录是否像存档文件夹，以及它本身是否是单个存档
+func (s *SaveService) describeDirectory(name, path string) BrowseEntry {
+	return BrowseEntry{
+		Name:         name,
+		Path:         path,
+		IsSaveFolder: s.containsValidSave(path),
+		IsSave:       s.isSaveDirectory(path),
+	}
+}
+
+// containsValidSave 检查目录中是否至少有一个可以解析的存档，找到第一个即返回
+func (s *SaveService) containsValidSave(rootPath string) bool {
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if s.isSaveDirectory(filepath.Join(rootPath, entry.Name())) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSaveDirectory 检查目录本身是否是一个可以解析的存档
+func (s *SaveService) isSaveDirectory(savePath string) bool {
+	saveFile := s.findMainSaveFile(savePath)
+	if saveFile == "" {
+		return false
+	}
+	_, err := s.parseSaveFile(saveFile)
+	return err == nil
+}
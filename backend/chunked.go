@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 分块上传的限制
+const (
+	maxChunkedUploadSize = maxImportUncompressedSize // 整个文件的大小上限
+	maxChunkSize         = 32 * 1024 * 1024          // 单个分块的大小上限
+	defaultChunkSize     = 8 * 1024 * 1024           // 建议客户端使用的分块大小
+	uploadSessionTTL     = 24 * time.Hour            // 超过这个时间没有新分块的会话会被清理
+)
+
+// uploadsDir 分块上传会话的存放目录，每个会话一个子目录，包含 session.json 和上传的文件
+const uploadsDir = "./temp/uploads"
+
+// uploadSessionDir 返回会话目录
+func uploadSessionDir(id string) string {
+	return filepath.Join(uploadsDir, id)
+}
+
+// uploadDataPath 返回会话中上传文件的位置，保留原文件名以便判断格式和推断存档名称
+func uploadDataPath(session *UploadSession) string {
+	return filepath.Join(uploadSessionDir(session.ID), session.Filename)
+}
+
+// saveUploadSession 把会话信息写入会话目录，服务重启后可以继续上传
+func saveUploadSession(session *UploadSession) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(uploadSessionDir(session.ID), "session.json"), data)
+}
+
+// loadUploadSessions 启动时加载未过期的上传会话，过期或损坏的会话目录直接删除
+func (s *SaveService) loadUploadSessions() {
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil {
+		return
+	}
+
+	s.uploadMu.Lock()
+	defer s.uploadMu.Unlock()
+
+	for _, entry := range entries {
+		dir := filepath.Join(uploadsDir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "session.json"))
+		if err != nil {
+			os.RemoveAll(dir)
+			continue
+		}
+
+		var session UploadSession
+		if err := json.Unmarshal(data, &session); err != nil || session.ID != entry.Name() || time.Now().After(session.ExpiresAt) {
+			os.RemoveAll(dir)
+			continue
+		}
+
+		// 以磁盘上实际写入的字节数为准
+		if info, err := os.Stat(uploadDataPath(&session)); err == nil && info.Size() < session.Offset {
+			session.Offset = info.Size()
+		}
+		s.uploads[session.ID] = &session
+	}
+}
+
+// requestUserID 返回当前请求的用户ID
+func requestUserID(c *gin.Context) string {
+	userID, _ := c.Get("user_id")
+	return fmt.Sprint(userID)
+}
+
+// requestUsername 返回当前请求的用户名
+func requestUsername(c *gin.Context) string {
+	username, _ := c.Get("username")
+	return fmt.Sprint(username)
+}
+
+// requestUploadSession 获取当前用户的上传会话，失败时直接写入错误响应
+func (s *SaveService) requestUploadSession(c *gin.Context) (*UploadSession, bool) {
+	s.uploadMu.Lock()
+	session, ok := s.uploads[c.Param("id")]
+	s.uploadMu.Unlock()
+
+	if !ok || session.Owner != requestUserID(c) {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "上传会话不存在或已过期",
+		})
+		return nil, false
+	}
+	return session, true
+}
+
+// InitUpload 创建分块上传会话
+func (s *SaveService) InitUpload(c *gin.Context) {
+	var req UploadInitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	filename := filepath.Base(req.Filename)
+	if filename != req.Filename || !isValidSaveName(filename) || uploadArchiveFormat(filename) == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "只支持 ZIP、TAR 和 TAR.GZ 格式的存档文件",
+		})
+		return
+	}
+
+	if req.Size <= 0 || req.Size > maxChunkedUploadSize {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("文件大小必须在 1 到 %d 字节之间", int64(maxChunkedUploadSize)),
+		})
+		return
+	}
+
+	// 完成上传时必须校验整个文件，否则无法区分上传中损坏的内容和导入失败
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if _, err := hex.DecodeString(req.SHA256); err != nil || len(req.SHA256) != 64 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "缺少 SHA-256 校验值或格式无效",
+		})
+		return
+	}
+
+	now := time.Now()
+	session := &UploadSession{
+		ID:        uuid.New().String(),
+		Filename:  filename,
+		Size:      req.Size,
+		SHA256:    req.SHA256,
+		ChunkSize: defaultChunkSize,
+		Owner:     requestUserID(c),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(uploadSessionTTL),
+	}
+
+	// 预先创建空文件，之后每个分块按偏移量写入
+	if err := os.MkdirAll(uploadSessionDir(session.ID), 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "创建上传会话失败: " + err.Error(),
+		})
+		return
+	}
+	file, err := os.Create(uploadDataPath(session))
+	if err == nil {
+		file.Close()
+		err = saveUploadSession(session)
+	}
+	if err != nil {
+		os.RemoveAll(uploadSessionDir(session.ID))
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "创建上传会话失败: " + err.Error(),
+		})
+		return
+	}
+
+	s.uploadMu.Lock()
+	s.uploads[session.ID] = session
+	s.uploadMu.Unlock()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    session,
+	})
+}
+
+// GetUpload 查询上传会话的状态，客户端据此从 offset 处继续上传
+func (s *SaveService) GetUpload(c *gin.Context) {
+	session, ok := s.requestUploadSession(c)
+	if !ok {
+		return
+	}
+
+	s.uploadMu.Lock()
+	status := *session
+	s.uploadMu.Unlock()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// UploadChunk 写入一个分块，请求体为分块的原始内容，offset 必须等于已上传的字节数
+func (s *SaveService) UploadChunk(c *gin.Context) {
+	session, ok := s.requestUploadSession(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "offset 参数无效",
+		})
+		return
+	}
+
+	// 同一会话同时只允许写入一个分块
+	s.uploadMu.Lock()
+	if session.writing || offset != session.Offset {
+		status := *session
+		s.uploadMu.Unlock()
+		c.JSON(http.StatusConflict, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("偏移量不匹配或有分块正在写入，应从 %d 继续上传", status.Offset),
+			Data:    status,
+		})
+		return
+	}
+	session.writing = true
+	s.uploadMu.Unlock()
+
+	written, err := writeUploadChunk(session, offset, c.Request.Body)
+
+	s.uploadMu.Lock()
+	session.writing = false
+	session.Offset = offset + written
+	session.UpdatedAt = time.Now()
+	session.ExpiresAt = session.UpdatedAt.Add(uploadSessionTTL)
+	status := *session
+	saveErr := saveUploadSession(session)
+	s.uploadMu.Unlock()
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "写入分块失败: " + err.Error(),
+			Data:    status,
+		})
+		return
+	}
+	if saveErr != nil {
+		log.Printf("保存上传会话失败: %v", saveErr)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// writeUploadChunk 从 offset 处写入分块并同步到磁盘，返回实际写入的字节数。
+// 分块超过大小限制或超出文件声明的大小时，超出部分不会被写入
+func writeUploadChunk(session *UploadSession, offset int64, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(uploadDataPath(session), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// 丢弃上次中断时写入但没有记录的内容
+	if err := file.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	limit := session.Size - offset
+	if limit > maxChunkSize {
+		limit = maxChunkSize
+	}
+	written, err := io.Copy(file, io.LimitReader(body, limit))
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+	if err != nil {
+		return written, err
+	}
+
+	// 请求体还有剩余内容说明分块过大或超出了文件大小
+	if n, _ := body.Read(make([]byte, 1)); n > 0 {
+		return written, fmt.Errorf("分块超过限制（单个分块最多 %d 字节，且不能超出文件大小）", int64(maxChunkSize))
+	}
+	return written, nil
+}
+
+// CompleteUpload 校验上传完成的文件并导入，导入没有成功时保留会话，可以修改参数后再次提交
+func (s *SaveService) CompleteUpload(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	session, ok := s.requestUploadSession(c)
+	if !ok {
+		return
+	}
+
+	req := ImportRequest{BackupExisting: true}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Error:   "请求参数无效",
+			})
+			return
+		}
+	}
+
+	s.uploadMu.Lock()
+	if session.writing || session.Offset != session.Size {
+		status := *session
+		s.uploadMu.Unlock()
+		c.JSON(http.StatusConflict, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("文件尚未上传完成: %d/%d 字节", status.Offset, status.Size),
+			Data:    status,
+		})
+		return
+	}
+	session.writing = true
+	s.uploadMu.Unlock()
+
+	// 导入结束前会话保持锁定，不能继续写入、重复提交或被清理
+	release := func() {
+		s.uploadMu.Lock()
+		session.writing = false
+		s.uploadMu.Unlock()
+	}
+
+	checksum, err := fileSHA256(uploadDataPath(session))
+	if err != nil || checksum != session.SHA256 {
+		release()
+		// 内容已经损坏，只能重新上传
+		s.removeUploadSession(session.ID)
+		s.addLog("import", fmt.Sprintf("分块上传校验失败: %s", session.Filename), false, "SHA-256 不匹配")
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "文件校验失败，SHA-256 不匹配，请重新上传",
+		})
+		return
+	}
+
+	// async=true 时在后台任务中转换格式并导入，任务结束时释放会话
+	if wantsAsync(c) {
+		submitted := s.submitJob(c, "import", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+			defer release()
+			return s.runImportJob(session.Filename, func() (*ImportResult, error) {
+				return s.importUpload(ctx, lib.Path, session, req, job)
+			})
+		})
+		if !submitted {
+			release()
+		}
+		return
+	}
+	defer release()
+
+	result, err := s.importUpload(context.Background(), lib.Path, session, req, noProgress{})
+	if err != nil {
+		s.addLog("import", fmt.Sprintf("导入存档失败: %s", session.Filename), false, err.Error())
+		s.respondConvertError(c, err)
+		return
+	}
+
+	s.logImportResult(result, session.Filename)
+	s.respondImportResult(c, result)
+}
+
+// importUpload 转换并导入已经校验过的上传文件，有存档导入成功时删除会话。
+// 文件本身没有问题，转换或导入失败时保留会话，修改参数后可以直接再次提交
+func (s *SaveService) importUpload(ctx context.Context, rootPath string, session *UploadSession, req ImportRequest, progress progressReporter) (*ImportResult, error) {
+	defer removeUploadArtifacts(session)
+
+	zipPath, err := s.convertToZip(uploadDataPath(session), uploadSessionDir(session.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.extractAndImportSave(ctx, rootPath, zipPath, req, progress)
+	if err != nil {
+		return result, err
+	}
+	if result.Imported > 0 {
+		s.removeUploadSession(session.ID)
+	}
+	return result, nil
+}
+
+// removeUploadArtifacts 删除转换格式和解密时在会话目录中生成的文件，只保留上传的文件和会话信息
+func removeUploadArtifacts(session *UploadSession) {
+	entries, err := os.ReadDir(uploadSessionDir(session.ID))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() != "session.json" && entry.Name() != session.Filename {
+			os.RemoveAll(filepath.Join(uploadSessionDir(session.ID), entry.Name()))
+		}
+	}
+}
+
+// CancelUpload 取消上传会话并删除已上传的内容
+func (s *SaveService) CancelUpload(c *gin.Context) {
+	session, ok := s.requestUploadSession(c)
+	if !ok {
+		return
+	}
+
+	// 正在写入分块或导入时删除目录会破坏进行中的请求
+	if status, removed := s.removeIdleUploadSession(session); !removed {
+		c.JSON(http.StatusConflict, APIResponse{
+			Success: false,
+			Error:   "有分块正在写入或正在导入，请稍后再取消",
+			Data:    status,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "上传已取消",
+	})
+}
+
+// removeUploadSession 删除会话及其目录
+func (s *SaveService) removeUploadSession(id string) {
+	s.uploadMu.Lock()
+	delete(s.uploads, id)
+	s.uploadMu.Unlock()
+
+	os.RemoveAll(uploadSessionDir(id))
+}
+
+// removeIdleUploadSession 在会话没有分块写入或导入时删除会话，检查和移出会话在同一次加锁中完成。
+// 返回会话当前的状态和是否已经删除
+func (s *SaveService) removeIdleUploadSession(session *UploadSession) (UploadSession, bool) {
+	s.uploadMu.Lock()
+	status := *session
+	if session.writing {
+		s.uploadMu.Unlock()
+		return status, false
+	}
+	delete(s.uploads, session.ID)
+	s.uploadMu.Unlock()
+
+	os.RemoveAll(uploadSessionDir(session.ID))
+	return status, true
+}
+
+// startUploadJanitor 定期清理过期的上传会话和异常退出遗留的临时文件
+func (s *SaveService) startUploadJanitor() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			s.cleanupUploads()
+			<-ticker.C
+		}
+	}()
+}
+
+// cleanupUploads 删除过期且没有正在写入的会话，以及 ./temp 下超过保留时间的临时目录
+func (s *SaveService) cleanupUploads() {
+	now := time.Now()
+
+	s.uploadMu.Lock()
+	var expired []*UploadSession
+	for _, session := range s.uploads {
+		if now.After(session.ExpiresAt) {
+			expired = append(expired, session)
+		}
+	}
+	s.uploadMu.Unlock()
+
+	for _, session := range expired {
+		if _, removed := s.removeIdleUploadSession(session); removed {
+			log.Printf("已清理过期的上传会话: %s", session.ID)
+		}
+	}
+
+	entries, err := os.ReadDir("./temp")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		// 上传会话和任务结果由各自的清理逻辑负责
+		if entry.Name() == filepath.Base(uploadsDir) || entry.Name() == filepath.Base(jobsDir) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < uploadSessionTTL {
+			continue
+		}
+		os.RemoveAll(filepath.Join("./temp", entry.Name()))
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chdirTemp 切换到临时目录，上传会话等使用相对路径的目录都在其中创建
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// newUploadTestRouter 注册上传接口，所有请求都以用户 1 的身份访问
+func newUploadTestRouter(s *SaveService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	r.PUT("/uploads/:id", s.UploadChunk)
+	r.DELETE("/uploads/:id", s.CancelUpload)
+	return r
+}
+
+// newTestUploadSession 创建一个空的上传会话
+func newTestUploadSession(t *testing.T, s *SaveService, size int64) *UploadSession {
+	t.Helper()
+	session := &UploadSession{
+		ID:        "test-session",
+		Filename:  "Farm_1.zip",
+		Size:      size,
+		Owner:     "1",
+		ExpiresAt: time.Now().Add(uploadSessionTTL),
+	}
+	if err := os.MkdirAll(uploadSessionDir(session.ID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(uploadDataPath(session), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.uploads[session.ID] = session
+	return session
+}
+
+func TestCancelUploadDuringWrite(t *testing.T) {
+	chdirTemp(t)
+	s := &SaveService{uploads: make(map[string]*UploadSession)}
+	r := newUploadTestRouter(s)
+	session := newTestUploadSession(t, s, 10)
+
+	// 分块的内容分两次到达，第一次到达后写入仍在进行
+	body, bodyWriter := io.Pipe()
+	chunkDone := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/uploads/test-session?offset=0", body))
+		chunkDone <- w
+	}()
+	if _, err := bodyWriter.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/uploads/test-session", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("cancel during write: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if _, err := os.Stat(uploadDataPath(session)); err != nil {
+		t.Fatalf("upload file removed during write: %v", err)
+	}
+
+	bodyWriter.Write([]byte("world"))
+	bodyWriter.Close()
+	if w := <-chunkDone; w.Code != http.StatusOK {
+		t.Fatalf("chunk: status = %d, body = %s", w.Code, w.Body)
+	}
+	if data, _ := os.ReadFile(uploadDataPath(session)); string(data) != "helloworld" {
+		t.Errorf("uploaded content = %q, want helloworld", data)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/uploads/test-session", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel after write: status = %d, body = %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(uploadSessionDir(session.ID)); !os.IsNotExist(err) {
+		t.Errorf("session directory still exists after cancel: %v", err)
+	}
+	if _, ok := s.uploads[session.ID]; ok {
+		t.Error("session still registered after cancel")
+	}
+}
+
+func TestCleanupUploadsKeepsBusySessions(t *testing.T) {
+	chdirTemp(t)
+	s := &SaveService{uploads: make(map[string]*UploadSession)}
+	session := newTestUploadSession(t, s, 10)
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+
+	// 导入中的会话即使过期也不清理
+	session.writing = true
+	s.cleanupUploads()
+	if _, ok := s.uploads[session.ID]; !ok {
+		t.Fatal("busy session was cleaned up")
+	}
+
+	session.writing = false
+	s.cleanupUploads()
+	if _, ok := s.uploads[session.ID]; ok {
+		t.Error("expired session was not cleaned up")
+	}
+	if _, err := os.Stat(uploadSessionDir(session.ID)); !os.IsNotExist(err) {
+		t.Errorf("expired session directory still exists: %v", err)
+	}
+}
+
+func TestUploadChunkRejectsWrongOffset(t *testing.T) {
+	chdirTemp(t)
+	s := &SaveService{uploads: make(map[string]*UploadSession)}
+	r := newUploadTestRouter(s)
+	newTestUploadSession(t, s, 10)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/uploads/test-session?offset=5", strings.NewReader("world")))
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
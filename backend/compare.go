@@ -0,0 +1,356 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCompareSaveSize 比较时读取的主存档文件大小上限
+const maxCompareSaveSize = 256 << 20 // 256MB
+
+// 存档版本的来源
+const (
+	VersionLive    = "live"
+	VersionBackup  = "backup"
+	VersionHistory = "history"
+	VersionUpload  = "upload"
+)
+
+// errVersionNotFound 要比较的存档版本不存在
+var errVersionNotFound = errors.New("存档版本不存在")
+
+// CompareSaves 比较两个存档版本，按金钱、背包、关系、建筑和任务列出 target 相对于 base 的变化。
+// 每个版本可以是当前存档、备份、历史快照或上传的压缩包；上传时使用 multipart，
+// base、target 字段为版本的 JSON，文件字段为 baseFile、targetFile
+func (s *SaveService) CompareSaves(c *gin.Context) {
+	var req CompareRequest
+	if c.ContentType() == "multipart/form-data" {
+		errBase := json.Unmarshal([]byte(c.PostForm("base")), &req.Base)
+		errTarget := json.Unmarshal([]byte(c.PostForm("target")), &req.Target)
+		if errBase != nil || errTarget != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Error:   "请求参数无效",
+			})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	tempDir, err := newTempDir("compare_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "创建临时目录失败",
+		})
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	base, baseLabel, err := s.loadSaveVersion(c, req.Base, "baseFile", tempDir)
+	if err != nil {
+		respondCompareError(c, "base", err)
+		return
+	}
+	target, targetLabel, err := s.loadSaveVersion(c, req.Target, "targetFile", tempDir)
+	if err != nil {
+		respondCompareError(c, "target", err)
+		return
+	}
+
+	diff := s.diffSaveDocuments(base, target)
+	diff.Base.Label = baseLabel
+	diff.Target.Label = targetLabel
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    diff,
+	})
+}
+
+// respondCompareError 写入比较失败的响应，which 表示出错的是 base 还是 target
+func respondCompareError(c *gin.Context, which string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errVersionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrGitNotInstalled):
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Error:   fmt.Sprintf("%s: %v", which, err),
+	})
+}
+
+// loadSaveVersion 读取并解析一个存档版本的主存档文件，返回解析结果和用于显示的标签
+func (s *SaveService) loadSaveVersion(c *gin.Context, ref SaveVersionRef, fileField, tempDir string) (*saveDocument, string, error) {
+	var data []byte
+	var label string
+	var err error
+
+	switch ref.Source {
+	case VersionLive:
+		data, label, err = s.readLiveVersion(ref)
+	case VersionBackup:
+		data, label, err = s.readBackupVersion(ref, tempDir)
+	case VersionHistory:
+		data, label, err = s.readHistoryVersion(c.Request.Context(), ref)
+	case VersionUpload:
+		data, label, err = s.readUploadedVersion(c, ref, fileField, tempDir)
+	default:
+		return nil, "", fmt.Errorf("不支持的来源: %s", ref.Source)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	doc, err := parseSaveDocument(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return doc, label, nil
+}
+
+// readLiveVersion 读取存档库中的当前存档
+func (s *SaveService) readLiveVersion(ref SaveVersionRef) ([]byte, string, error) {
+	lib, ok := s.getLibrary(ref.Library)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: 存档库 %s 不存在", errVersionNotFound, ref.Library)
+	}
+	save, err := s.getSaveByID(lib.Path, ref.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: 存档 %s 不存在", errVersionNotFound, ref.ID)
+	}
+	mainFile := s.findMainSaveFile(save.Path)
+	if mainFile == "" {
+		return nil, "", fmt.Errorf("存档 %s 中没有主存档文件", ref.ID)
+	}
+
+	data, err := readLimitedFile(mainFile)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, fmt.Sprintf("当前存档 %s/%s", lib.Name, save.Name), nil
+}
+
+// readBackupVersion 读取备份目录中的备份，加密的备份会先解密
+func (s *SaveService) readBackupVersion(ref SaveVersionRef, tempDir string) ([]byte, string, error) {
+	if !isSafeBackupName(ref.Backup) {
+		return nil, "", fmt.Errorf("%w: 备份 %s 不存在", errVersionNotFound, ref.Backup)
+	}
+	backupPath := filepath.Join(backupsDir, ref.Backup)
+	if info, err := os.Stat(backupPath); err != nil || info.IsDir() {
+		return nil, "", fmt.Errorf("%w: 备份 %s 不存在", errVersionNotFound, ref.Backup)
+	}
+
+	dir, err := os.MkdirTemp(tempDir, "backup_")
+	if err != nil {
+		return nil, "", err
+	}
+	zipPath, err := s.convertToZip(backupPath, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := readArchiveSaveFile(zipPath, ref.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "备份 " + ref.Backup, nil
+}
+
+// readUploadedVersion 读取随请求上传的压缩包
+func (s *SaveService) readUploadedVersion(c *gin.Context, ref SaveVersionRef, fileField, tempDir string) ([]byte, string, error) {
+	file, err := c.FormFile(fileField)
+	if err != nil {
+		return nil, "", fmt.Errorf("缺少上传的文件 %s", fileField)
+	}
+	if file.Size > maxUploadSize {
+		return nil, "", fmt.Errorf("文件大小超过限制(100MB)")
+	}
+	if uploadArchiveFormat(file.Filename) == "" {
+		return nil, "", fmt.Errorf("只支持 ZIP、TAR 和 TAR.GZ 格式的存档文件")
+	}
+
+	dir, err := os.MkdirTemp(tempDir, "upload_")
+	if err != nil {
+		return nil, "", err
+	}
+	uploadPath := filepath.Join(dir, filepath.Base(file.Filename))
+	if err := c.SaveUploadedFile(file, uploadPath); err != nil {
+		return nil, "", fmt.Errorf("保存文件失败: %v", err)
+	}
+	zipPath, err := s.convertToZip(uploadPath, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := readArchiveSaveFile(zipPath, ref.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "上传 " + filepath.Base(file.Filename), nil
+}
+
+// readHistoryVersion 读取历史快照，commit 可以是提交哈希或标签，为空时使用最新的快照
+func (s *SaveService) readHistoryVersion(ctx context.Context, ref SaveVersionRef) ([]byte, string, error) {
+	lib, ok := s.getLibrary(ref.Library)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: 存档库 %s 不存在", errVersionNotFound, ref.Library)
+	}
+	name := ref.ID
+	if save, err := s.getSaveByID(lib.Path, ref.ID); err == nil {
+		name = filepath.Base(save.Path)
+	} else if !isValidSaveName(name) {
+		return nil, "", fmt.Errorf("%w: 存档 %s 不存在", errVersionNotFound, ref.ID)
+	}
+
+	repo, err := historyRepo(lib.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	branch := historyBranch(name)
+	commit := repo.revParse(ctx, "refs/heads/"+branch+"^{commit}")
+	if commit == "" {
+		return nil, "", fmt.Errorf("%w: 存档 %s 没有历史记录", errVersionNotFound, name)
+	}
+	if ref.Commit != "" {
+		if commit, err = resolveHistoryRevision(ctx, repo, branch, ref.Commit); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", errVersionNotFound, err)
+		}
+	}
+
+	data, err := readHistorySaveFile(ctx, repo, commit, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, fmt.Sprintf("快照 %s@%s", name, commit[:12]), nil
+}
+
+// readHistorySaveFile 读取快照中的主存档文件：与目录同名的文件，没有时使用第一个候选文件
+func readHistorySaveFile(ctx context.Context, repo *gitRepo, commit, name string) ([]byte, error) {
+	out, err := repo.git(ctx, nil, "ls-tree", commit)
+	if err != nil {
+		return nil, err
+	}
+	mainFile := ""
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		// 每行为 "<mode> <type> <hash>\t<name>"，只看第一层的文件
+		meta, file, ok := strings.Cut(line, "\t")
+		if !ok || !strings.Contains(meta, " blob ") {
+			continue
+		}
+		if file == name {
+			mainFile = file
+			break
+		}
+		if mainFile == "" && isCandidateSaveFileName(file) {
+			mainFile = file
+		}
+	}
+	if mainFile == "" {
+		return nil, fmt.Errorf("快照中没有主存档文件")
+	}
+
+	data, truncated, err := repo.gitLimited(ctx, maxCompareSaveSize, "cat-file", "blob", commit+":"+mainFile)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, fmt.Errorf("主存档文件超过 %d 字节", int64(maxCompareSaveSize))
+	}
+	return []byte(data), nil
+}
+
+// readArchiveSaveFile 读取压缩包中存档的主存档文件。压缩包中有多个存档时需要用 id 指定存档目录名
+func readArchiveSaveFile(zipPath, id string) ([]byte, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	if err := validateZipEntries(reader.File); err != nil {
+		return nil, err
+	}
+	saves, err := detectZipSaves(reader.File, zipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var save *archiveSave
+	for i := range saves {
+		if saves[i].Name == id || (id == "" && len(saves) == 1) {
+			save = &saves[i]
+			break
+		}
+	}
+	if save == nil {
+		names := make([]string, 0, len(saves))
+		for _, save := range saves {
+			names = append(names, save.Name)
+		}
+		if id == "" {
+			return nil, fmt.Errorf("压缩包中有多个存档，需要用 id 指定: %s", strings.Join(names, ", "))
+		}
+		return nil, fmt.Errorf("%w: 压缩包中没有存档 %s，包含: %s", errVersionNotFound, id, strings.Join(names, ", "))
+	}
+
+	var mainFile *zip.File
+	for _, file := range reader.File {
+		name := cleanArchiveName(file.Name)
+		if file.FileInfo().IsDir() || !strings.HasPrefix(name, save.Prefix) {
+			continue
+		}
+		relPath := strings.TrimPrefix(name, save.Prefix)
+		if strings.Contains(relPath, "/") {
+			continue
+		}
+		if relPath == save.Name {
+			mainFile = file
+			break
+		}
+		if mainFile == nil && isCandidateSaveFileName(relPath) {
+			mainFile = file
+		}
+	}
+	if mainFile == nil {
+		return nil, fmt.Errorf("压缩包中没有存档 %s 的主存档文件", save.Name)
+	}
+	if mainFile.UncompressedSize64 > maxCompareSaveSize {
+		return nil, fmt.Errorf("主存档文件超过 %d 字节", int64(maxCompareSaveSize))
+	}
+
+	file, err := mainFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxCompareSaveSize))
+}
+
+// readLimitedFile 读取文件，超过 maxCompareSaveSize 时报错
+func readLimitedFile(filePath string) ([]byte, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxCompareSaveSize {
+		return nil, fmt.Errorf("主存档文件超过 %d 字节", int64(maxCompareSaveSize))
+	}
+	return os.ReadFile(filePath)
+}
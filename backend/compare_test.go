@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeTestZipFile 把 files（路径 → 内容）写成压缩包
+func writeTestZipFile(t *testing.T, zipPath string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newCompareTestService 创建只有一个存档库的服务，存档库中有 Farm_200，内容为 diffBase16Save
+func newCompareTestService(t *testing.T) *SaveService {
+	t.Helper()
+	root := filepath.Join(chdirTemp(t), "saves")
+	if err := os.MkdirAll(filepath.Join(root, "Farm_200"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "Farm_200", "Farm_200"), []byte(diffBase16Save), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newRootedService(t, root)
+	s.libraries[defaultLibraryName] = SaveLibrary{Name: defaultLibraryName, Path: root}
+	return s
+}
+
+// postCompare 调用比较接口，body 为 JSON 或 multipart
+func postCompare(t *testing.T, s *SaveService, contentType string, body *bytes.Buffer) (int, APIResponse, *SaveDiff) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/saves/compare", s.CompareSaves)
+
+	req := httptest.NewRequest(http.MethodPost, "/saves/compare", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp struct {
+		APIResponse
+		Data *SaveDiff `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v: %s", err, w.Body)
+	}
+	return w.Code, resp.APIResponse, resp.Data
+}
+
+func compareJSON(t *testing.T, req CompareRequest) *bytes.Buffer {
+	t.Helper()
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewBuffer(data)
+}
+
+func TestCompareLiveWithUpload(t *testing.T) {
+	s := newCompareTestService(t)
+
+	zipPath := filepath.Join(t.TempDir(), "next-day.zip")
+	writeTestZipFile(t, zipPath, map[string]string{"Farm_200/Farm_200": diffTarget16Save})
+	zipData, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("base", `{"source": "live", "id": "Farm_200"}`)
+	writer.WriteField("target", `{"source": "upload"}`)
+	part, err := writer.CreateFormFile("targetFile", "next-day.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(zipData)
+	writer.Close()
+
+	status, resp, diff := postCompare(t, s, writer.FormDataContentType(), &body)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if diff.Base.Label != "当前存档 default/Farm_200" || diff.Target.Label != "上传 next-day.zip" {
+		t.Errorf("labels = %q, %q", diff.Base.Label, diff.Target.Label)
+	}
+	if diff.Identical || len(diff.Farmhands) != 2 || diff.Farmhands[0].Player != "Bob" || diff.Farmhands[1].Player != "Erin" {
+		t.Errorf("farmhands = %+v, want Bob changed and Erin added", diff.Farmhands)
+	}
+
+	// 临时文件在请求结束后被删除
+	if entries, _ := os.ReadDir("temp"); len(entries) != 0 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestCompareBackupWithLive(t *testing.T) {
+	s := newCompareTestService(t)
+	if err := os.MkdirAll(backupsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestZipFile(t, filepath.Join(backupsDir, "Farm_200_20240101_120000.zip"), map[string]string{
+		"Farm_200/Farm_200": diffBase16Save,
+	})
+
+	status, resp, diff := postCompare(t, s, "application/json", compareJSON(t, CompareRequest{
+		Base:   SaveVersionRef{Source: VersionBackup, Backup: "Farm_200_20240101_120000.zip"},
+		Target: SaveVersionRef{Source: VersionLive, ID: "Farm_200"},
+	}))
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if !diff.Identical {
+		t.Errorf("backup of the live save differs: %q", diff.Summary)
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	s := newCompareTestService(t)
+	live := SaveVersionRef{Source: VersionLive, ID: "Farm_200"}
+
+	tests := []struct {
+		name   string
+		req    CompareRequest
+		status int
+		error  string
+	}{
+		{"missing save", CompareRequest{Base: SaveVersionRef{Source: VersionLive, ID: "Farm_999"}, Target: live}, http.StatusNotFound, "base:"},
+		{"missing library", CompareRequest{Base: live, Target: SaveVersionRef{Source: VersionLive, Library: "other", ID: "Farm_200"}}, http.StatusNotFound, "target:"},
+		{"missing backup", CompareRequest{Base: SaveVersionRef{Source: VersionBackup, Backup: "none.zip"}, Target: live}, http.StatusNotFound, "base:"},
+		{"unsafe backup name", CompareRequest{Base: SaveVersionRef{Source: VersionBackup, Backup: "../saves/Farm_200"}, Target: live}, http.StatusNotFound, "base:"},
+		{"unknown source", CompareRequest{Base: live, Target: SaveVersionRef{Source: "ftp"}}, http.StatusBadRequest, "target:"},
+	}
+	for _, tt := range tests {
+		status, resp, _ := postCompare(t, s, "application/json", compareJSON(t, tt.req))
+		if status != tt.status || !strings.HasPrefix(resp.Error, tt.error) {
+			t.Errorf("%s: status = %d, error = %q, want %d and %q", tt.name, status, resp.Error, tt.status, tt.error)
+		}
+	}
+}
+
+func TestReadArchiveSaveFile(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "saves.zip")
+	writeTestZipFile(t, zipPath, map[string]string{
+		"Farm_200/Farm_200":         diffBase16Save,
+		"Farm_200/SaveGameInfo":     "<Farmer />",
+		"Farm_200/Farm_200_old":     "old",
+		"Farm_500/Farm_500":         diffBase15Save,
+		"Farm_500/nested/Farm_500":  "not the main file",
+		"Farm_500/stardew-note.txt": "note",
+	})
+
+	data, err := readArchiveSaveFile(zipPath, "Farm_500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != diffBase15Save {
+		t.Errorf("read %d bytes, want the main file of Farm_500", len(data))
+	}
+
+	if _, err := readArchiveSaveFile(zipPath, ""); err == nil || !strings.Contains(err.Error(), "Farm_200, Farm_500") {
+		t.Errorf("no id with several saves: %v", err)
+	}
+	if _, err := readArchiveSaveFile(zipPath, "Farm_999"); !errors.Is(err, errVersionNotFound) {
+		t.Errorf("unknown id: %v, want errVersionNotFound", err)
+	}
+
+	single := filepath.Join(t.TempDir(), "single.zip")
+	writeTestZipFile(t, single, map[string]string{"Farm_200/Farm_200": diffBase16Save})
+	if data, err := readArchiveSaveFile(single, ""); err != nil || string(data) != diffBase16Save {
+		t.Errorf("single save without id: %d bytes, %v", len(data), err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// configFilePath 返回路径配置文件位置，可通过 SAVE_CONFIG_FILE 环境变量覆盖
+func configFilePath() string {
+	if path := os.Getenv("SAVE_CONFIG_FILE"); path != "" {
+		return path
+	}
+	return "config.json"
+}
+
+// loadConfig 从配置文件加载存档库和最近路径
+func (s *SaveService) loadConfig() error {
+	data, err := os.ReadFile(configFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // 文件不存在，使用自动检测
+		}
+		return err
+	}
+
+	var config ServiceConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, lib := range config.Libraries {
+		if !isValidSaveName(lib.Name) || lib.Path == "" {
+			continue
+		}
+		s.libraries[lib.Name] = SaveLibrary{
+			Name:        lib.Name,
+			Path:        filepath.Clean(lib.Path),
+			Description: lib.Description,
+		}
+	}
+	if config.DefaultLibrary != "" {
+		s.defaultLibrary = config.DefaultLibrary
+	}
+	if config.RecentPaths != nil {
+		s.recentPaths = config.RecentPaths
+	}
+	s.configCandidates = config.PathCandidates
+	s.configRoots = config.AllowedRoots
+	s.configInbox = config.ImportInbox
+	s.configInboxInterval = config.InboxWatchInterval
+	s.configZipCompression = config.ZipCompression
+	s.configZipDeterministic = config.ZipDeterministic
+	s.configBackupTargets = config.BackupTargets
+	s.configHistoryEnabled = config.HistoryEnabled
+
+	return nil
+}
+
+// saveConfig 将存档库和最近路径写入配置文件
+func (s *SaveService) saveConfig() error {
+	s.mu.RLock()
+	config := ServiceConfig{
+		Libraries:      make([]LibraryConfig, 0, len(s.libraries)),
+		DefaultLibrary: s.defaultLibrary,
+		RecentPaths:    append([]string(nil), s.recentPaths...),
+		PathCandidates: s.configCandidates,
+		AllowedRoots:   s.configRoots,
+
+		ImportInbox:        s.configInbox,
+		InboxWatchInterval: s.configInboxInterval,
+
+		ZipCompression:   s.configZipCompression,
+		ZipDeterministic: s.configZipDeterministic,
+
+		BackupTargets:  s.configBackupTargets,
+		HistoryEnabled: s.configHistoryEnabled,
+	}
+	for _, lib := range s.libraries {
+		config.Libraries = append(config.Libraries, LibraryConfig{
+			Name:        lib.Name,
+			Path:        lib.Path,
+			Description: lib.Description,
+		})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(config.Libraries, func(i, j int) bool {
+		return config.Libraries[i].Name < config.Libraries[j].Name
+	})
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(configFilePath(), data)
+}
+
+// persistConfig 保存配置，失败时只记录日志，不影响当前请求
+func (s *SaveService) persistConfig() {
+	if err := s.saveConfig(); err != nil {
+		log.Printf("保存路径配置失败: %v", err)
+	}
+}
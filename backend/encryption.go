@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedSuffix 加密文件在原文件名后追加的扩展名
+const encryptedSuffix = ".enc"
+
+// 加密文件格式：文件头（魔数、密钥派生方式、随机盐）之后是若干个 AES-256-GCM 加密的分块。
+// 每个文件用盐派生出独立的密钥，分块的 nonce 由分块序号和“最后一块”标记组成，
+// 分块被删除、调换或文件被截断都会导致解密失败。文件头作为附加数据参与每个分块的认证
+const (
+	encryptionChunkSize = 64 * 1024
+	encryptionSaltSize  = 16
+	encryptionKeySize   = 32
+)
+
+// encryptionMagic 加密文件的魔数，导入时据此识别加密文件，与文件名无关
+var encryptionMagic = []byte("SSMENC01")
+
+// 文件密钥的派生方式
+const (
+	kdfKey        byte = 1 // 由 SAVE_ENCRYPTION_KEY 通过 HMAC-SHA256 派生
+	kdfPassphrase byte = 2 // 由 SAVE_ENCRYPTION_PASSPHRASE 通过 scrypt 派生
+)
+
+// ErrEncryptionNotConfigured 需要加密或解密但服务器没有配置密钥
+var ErrEncryptionNotConfigured = errors.New("服务器未配置加密密钥（SAVE_ENCRYPTION_KEY 或 SAVE_ENCRYPTION_PASSPHRASE）")
+
+// archiveSecret 配置的加密密钥或口令
+type archiveSecret struct {
+	key        []byte
+	passphrase []byte
+}
+
+// encryptionSecret 读取环境变量中的加密配置：SAVE_ENCRYPTION_KEY 为 32 字节密钥的十六进制或 base64 编码，
+// SAVE_ENCRYPTION_PASSPHRASE 为口令。都没有配置时返回 nil
+func encryptionSecret() (*archiveSecret, error) {
+	secret := &archiveSecret{}
+	if value := strings.TrimSpace(os.Getenv("SAVE_ENCRYPTION_KEY")); value != "" {
+		key, err := hex.DecodeString(value)
+		if err != nil {
+			key, err = base64.StdEncoding.DecodeString(value)
+		}
+		if err != nil || len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("SAVE_ENCRYPTION_KEY 必须是 32 字节密钥的十六进制或 base64 编码")
+		}
+		secret.key = key
+	}
+	if value := os.Getenv("SAVE_ENCRYPTION_PASSPHRASE"); value != "" {
+		secret.passphrase = []byte(value)
+	}
+
+	if secret.key == nil && secret.passphrase == nil {
+		return nil, nil
+	}
+	return secret, nil
+}
+
+// requireEncryptionSecret 返回加密配置，没有配置时返回错误
+func requireEncryptionSecret() (*archiveSecret, error) {
+	secret, err := encryptionSecret()
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrEncryptionNotConfigured
+	}
+	return secret, nil
+}
+
+// deriveFileKey 用文件头中的盐派生该文件的密钥
+func (s *archiveSecret) deriveFileKey(kdf byte, salt []byte) ([]byte, error) {
+	switch kdf {
+	case kdfKey:
+		if s.key == nil {
+			return nil, fmt.Errorf("文件使用密钥加密，但服务器未配置 SAVE_ENCRYPTION_KEY")
+		}
+		mac := hmac.New(sha256.New, s.key)
+		mac.Write([]byte("stardew-save-manager/archive"))
+		mac.Write(salt)
+		return mac.Sum(nil), nil
+	case kdfPassphrase:
+		if s.passphrase == nil {
+			return nil, fmt.Errorf("文件使用口令加密，但服务器未配置 SAVE_ENCRYPTION_PASSPHRASE")
+		}
+		return scrypt.Key(s.passphrase, salt, 1<<15, 8, 1, encryptionKeySize)
+	}
+	return nil, fmt.Errorf("不支持的密钥派生方式: %d", kdf)
+}
+
+// newArchiveAEAD 创建文件密钥对应的 AES-GCM
+func newArchiveAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 分块的 nonce：前 11 字节为分块序号，最后 1 字节标记是否为最后一块
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter 分块加密写入，Close 时写入最后一块，不会关闭底层的 Writer
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint64
+}
+
+// newEncryptWriter 写入文件头并返回加密的 Writer，配置了密钥时优先使用密钥
+func newEncryptWriter(w io.Writer, secret *archiveSecret) (io.WriteCloser, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	kdf := kdfPassphrase
+	if secret.key != nil {
+		kdf = kdfKey
+	}
+	key, err := secret.deriveFileKey(kdf, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newArchiveAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append(append([]byte{}, encryptionMagic...), kdf), salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, encryptionChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区满且还有数据时才写出，保证最后一块在 Close 时写出
+		if len(e.buf) == encryptionChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encryptionChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+// seal 加密并写出缓冲区中的分块
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.counter, last), e.buf, e.header)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader 分块解密读取，读到最后一块之前遇到文件结尾视为文件被截断
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+// newDecryptReader 读取文件头并返回解密的 Reader
+func newDecryptReader(r io.Reader, secret *archiveSecret) (io.Reader, error) {
+	header := make([]byte, len(encryptionMagic)+1+encryptionSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(encryptionMagic)], encryptionMagic) {
+		return nil, fmt.Errorf("不是加密文件")
+	}
+
+	key, err := secret.deriveFileKey(header[len(encryptionMagic)], header[len(encryptionMagic)+1:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newArchiveAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		header: header,
+		chunk:  make([]byte, encryptionChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open 读取并解密下一个分块
+func (d *decryptReader) open() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		_, peekErr := d.r.Peek(1)
+		last = peekErr == io.EOF
+	}
+
+	plain, err := d.aead.Open(d.chunk[:0], chunkNonce(d.counter, last), d.chunk[:n], d.header)
+	if err != nil {
+		return fmt.Errorf("解密失败：密钥不正确或文件已损坏")
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+// isEncryptedFile 根据文件头判断文件是否已加密
+func isEncryptedFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(encryptionMagic))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, encryptionMagic)
+}
+
+// decryptFile 把加密文件解密到 targetPath，解密失败时删除不完整的输出
+func decryptFile(srcPath, targetPath string) error {
+	secret, err := requireEncryptionSecret()
+	if err != nil {
+		return fmt.Errorf("文件已加密: %v", err)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	reader, err := newDecryptReader(src, secret)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(targetPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, reader)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(targetPath)
+	}
+	return err
+}
+
+// encryptedName 配置了加密时在文件名后追加 .enc
+func encryptedName(name string, secret *archiveSecret) string {
+	if secret != nil {
+		return name + encryptedSuffix
+	}
+	return name
+}
+
+// createArchiveFile 创建备份或导出文件，配置了加密时返回加密的 Writer。
+// 返回的关闭函数先写出最后的加密分块再关闭文件
+func createArchiveFile(filePath string, secret *archiveSecret) (io.Writer, func() error, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if secret == nil {
+		return file, file.Close, nil
+	}
+
+	writer, err := newEncryptWriter(file, secret)
+	if err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return nil, nil, err
+	}
+	closeFn := func() error {
+		err := writer.Close()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return writer, closeFn, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 加密文件中文件头和完整分块的长度
+const (
+	testHeaderSize      = 8 + 1 + encryptionSaltSize
+	testSealedChunkSize = encryptionChunkSize + 16
+)
+
+func testKeySecret(t *testing.T) *archiveSecret {
+	t.Helper()
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return &archiveSecret{key: key}
+}
+
+func testPassphraseSecret() *archiveSecret {
+	return &archiveSecret{passphrase: []byte("correct horse battery staple")}
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// encryptBytes 分多次不等长地写入，覆盖分块缓冲的各种边界
+func encryptBytes(t *testing.T, secret *archiveSecret, plain []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	writer, err := newEncryptWriter(&out, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rest, step := plain, 1; len(rest) > 0; step = step*3 + 1 {
+		n := step
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if _, err := writer.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func decryptBytes(secret *archiveSecret, data []byte) ([]byte, error) {
+	reader, err := newDecryptReader(bytes.NewReader(data), secret)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	sizes := []int{
+		0,
+		1,
+		encryptionChunkSize - 1,
+		encryptionChunkSize,
+		encryptionChunkSize + 1,
+		2 * encryptionChunkSize,
+		3*encryptionChunkSize + 7,
+	}
+	secrets := map[string]*archiveSecret{
+		"key":        testKeySecret(t),
+		"passphrase": testPassphraseSecret(),
+	}
+	for name, secret := range secrets {
+		for _, size := range sizes {
+			plain := randomBytes(t, size)
+			encrypted := encryptBytes(t, secret, plain)
+
+			// 每个分块都有认证标签，最后一块即使为空也会写出
+			chunks := size/encryptionChunkSize + 1
+			if size > 0 && size%encryptionChunkSize == 0 {
+				chunks--
+			}
+			if want := testHeaderSize + size + chunks*16; len(encrypted) != want {
+				t.Errorf("%s/%d: encrypted size = %d, want %d", name, size, len(encrypted), want)
+			}
+
+			decrypted, err := decryptBytes(secret, encrypted)
+			if err != nil {
+				t.Fatalf("%s/%d: decrypt: %v", name, size, err)
+			}
+			if !bytes.Equal(decrypted, plain) {
+				t.Errorf("%s/%d: decrypted content differs", name, size)
+			}
+		}
+	}
+}
+
+func TestEncryptionKeyDerivation(t *testing.T) {
+	keySecret := testKeySecret(t)
+	passSecret := testPassphraseSecret()
+
+	// 配置了密钥时优先使用密钥
+	both := &archiveSecret{key: keySecret.key, passphrase: passSecret.passphrase}
+	encrypted := encryptBytes(t, both, []byte("hello"))
+	if kdf := encrypted[len(encryptionMagic)]; kdf != kdfKey {
+		t.Errorf("kdf = %d, want key", kdf)
+	}
+	if _, err := decryptBytes(keySecret, encrypted); err != nil {
+		t.Errorf("decrypt with key only: %v", err)
+	}
+	if _, err := decryptBytes(passSecret, encrypted); err == nil {
+		t.Error("decrypt key file with passphrase only succeeded")
+	}
+
+	encrypted = encryptBytes(t, passSecret, []byte("hello"))
+	if kdf := encrypted[len(encryptionMagic)]; kdf != kdfPassphrase {
+		t.Errorf("kdf = %d, want passphrase", kdf)
+	}
+	if _, err := decryptBytes(keySecret, encrypted); err == nil {
+		t.Error("decrypt passphrase file with key only succeeded")
+	}
+
+	// 同样的内容每次加密使用不同的盐
+	if bytes.Equal(encryptBytes(t, keySecret, []byte("hello")), encryptBytes(t, keySecret, []byte("hello"))) {
+		t.Error("two encryptions of the same content are identical")
+	}
+}
+
+func TestEncryptionRejectsWrongSecret(t *testing.T) {
+	for name, secrets := range map[string][2]*archiveSecret{
+		"key":        {testKeySecret(t), testKeySecret(t)},
+		"passphrase": {testPassphraseSecret(), {passphrase: []byte("wrong")}},
+	} {
+		encrypted := encryptBytes(t, secrets[0], randomBytes(t, 1000))
+		if _, err := decryptBytes(secrets[1], encrypted); err == nil {
+			t.Errorf("%s: decrypt with wrong secret succeeded", name)
+		}
+	}
+}
+
+func TestEncryptionDetectsTampering(t *testing.T) {
+	secrets := map[string]*archiveSecret{
+		"key":        testKeySecret(t),
+		"passphrase": testPassphraseSecret(),
+	}
+	for name, secret := range secrets {
+		plain := randomBytes(t, 3*encryptionChunkSize+100)
+		encrypted := encryptBytes(t, secret, plain)
+		chunk := func(i int) []byte {
+			start := testHeaderSize + i*testSealedChunkSize
+			end := start + testSealedChunkSize
+			if end > len(encrypted) {
+				end = len(encrypted)
+			}
+			return encrypted[start:end]
+		}
+		join := func(parts ...[]byte) []byte {
+			return bytes.Join(parts, nil)
+		}
+		flip := func(offset int) []byte {
+			data := append([]byte(nil), encrypted...)
+			data[offset] ^= 0x01
+			return data
+		}
+		header := encrypted[:testHeaderSize]
+
+		tests := []struct {
+			name string
+			data []byte
+		}{
+			{"last chunk removed", encrypted[:testHeaderSize+3*testSealedChunkSize]},
+			{"last chunk truncated", encrypted[:len(encrypted)-1]},
+			{"truncated inside a chunk", encrypted[:testHeaderSize+testSealedChunkSize+10]},
+			{"only the header", header},
+			{"truncated header", encrypted[:testHeaderSize-1]},
+			{"empty", nil},
+			{"chunks swapped", join(header, chunk(1), chunk(0), chunk(2), chunk(3))},
+			{"chunk duplicated", join(header, chunk(0), chunk(0), chunk(2), chunk(3))},
+			{"middle chunk removed", join(header, chunk(0), chunk(2), chunk(3))},
+			{"last chunk moved to the front", join(header, chunk(3), chunk(1), chunk(2), chunk(0))},
+			{"trailing data", join(encrypted, []byte{0})},
+			{"ciphertext modified", flip(testHeaderSize + testSealedChunkSize + 5)},
+			{"tag modified", flip(len(encrypted) - 1)},
+			{"magic modified", flip(0)},
+			{"kdf modified", flip(len(encryptionMagic))},
+			{"salt modified", flip(len(encryptionMagic) + 1)},
+		}
+		for _, tt := range tests {
+			decrypted, err := decryptBytes(secret, tt.data)
+			if err == nil {
+				t.Errorf("%s/%s: decrypt succeeded with %d bytes", name, tt.name, len(decrypted))
+			}
+		}
+
+		// 替换文件头后，原来的分块无法通过认证
+		other := encryptBytes(t, secret, plain)
+		if _, err := decryptBytes(secret, join(other[:testHeaderSize], encrypted[testHeaderSize:])); err == nil {
+			t.Errorf("%s: decrypt with another file's header succeeded", name)
+		}
+	}
+}
+
+func TestEncryptionSecretFromEnv(t *testing.T) {
+	key := randomBytes(t, encryptionKeySize)
+	tests := []struct {
+		name, key, passphrase string
+		wantErr, wantNil      bool
+	}{
+		{name: "nothing configured", wantNil: true},
+		{name: "hex key", key: hex.EncodeToString(key)},
+		{name: "base64 key", key: base64.StdEncoding.EncodeToString(key)},
+		{name: "key with whitespace", key: " " + hex.EncodeToString(key) + "\n"},
+		{name: "short key", key: hex.EncodeToString(key[:16]), wantErr: true},
+		{name: "invalid key", key: "not a key", wantErr: true},
+		{name: "passphrase", passphrase: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SAVE_ENCRYPTION_KEY", tt.key)
+			t.Setenv("SAVE_ENCRYPTION_PASSPHRASE", tt.passphrase)
+			secret, err := encryptionSecret()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encryptionSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (secret == nil) != tt.wantNil {
+				t.Fatalf("encryptionSecret() = %v, wantNil %v", secret, tt.wantNil)
+			}
+			if tt.key != "" && !bytes.Equal(secret.key, key) {
+				t.Errorf("key = %x, want %x", secret.key, key)
+			}
+		})
+	}
+}
+
+func TestDecryptFile(t *testing.T) {
+	key := randomBytes(t, encryptionKeySize)
+	t.Setenv("SAVE_ENCRYPTION_KEY", hex.EncodeToString(key))
+	t.Setenv("SAVE_ENCRYPTION_PASSPHRASE", "")
+
+	dir := t.TempDir()
+	plain := randomBytes(t, 2*encryptionChunkSize+3)
+	encrypted := encryptBytes(t, &archiveSecret{key: key}, plain)
+
+	srcPath := filepath.Join(dir, "save.zip.enc")
+	if err := os.WriteFile(srcPath, encrypted, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedFile(srcPath) {
+		t.Error("isEncryptedFile() = false, want true")
+	}
+	targetPath := filepath.Join(dir, "save.zip")
+	if err := decryptFile(srcPath, targetPath); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(targetPath); !bytes.Equal(data, plain) {
+		t.Error("decrypted file differs")
+	}
+
+	// 解密失败时不留下不完整的输出
+	truncatedPath := filepath.Join(dir, "truncated.zip.enc")
+	if err := os.WriteFile(truncatedPath, encrypted[:testHeaderSize+testSealedChunkSize], 0644); err != nil {
+		t.Fatal(err)
+	}
+	partialPath := filepath.Join(dir, "truncated.zip")
+	if err := decryptFile(truncatedPath, partialPath); err == nil {
+		t.Fatal("decryptFile() of a truncated file succeeded")
+	}
+	if _, err := os.Stat(partialPath); !os.IsNotExist(err) {
+		t.Error("partial output was not removed")
+	}
+
+	if isEncryptedFile(targetPath) {
+		t.Error("isEncryptedFile() of plain zip = true")
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// downloadLinkTTL 批量导出下载链接的有效期
+const downloadLinkTTL = 15 * time.Minute
+
+// downloadClaims 下载链接中签名的内容
+type downloadClaims struct {
+	Library  string   `json:"library"`
+	SaveIDs  []string `json:"saveIds"`
+	Username string   `json:"username"`
+	Encrypt  bool     `json:"encrypt,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// downloadSigningKey 由 JWT 密钥派生的下载链接签名密钥，下载链接不能当作登录令牌使用
+func downloadSigningKey() []byte {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("stardew-save-manager/download-link"))
+	return mac.Sum(nil)
+}
+
+// exportSecret 请求中 encrypt=true 时返回加密配置，服务器未配置加密时写入错误响应
+func exportSecret(c *gin.Context) (*archiveSecret, bool) {
+	if c.Query("encrypt") != "true" {
+		return nil, true
+	}
+
+	secret, err := requireEncryptionSecret()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return secret, true
+}
+
+// ExportSave 导出存档，直接把ZIP写入响应，不在服务器上生成临时文件
+func (s *SaveService) ExportSave(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+	secret, ok := exportSecret(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	save, err := s.getSaveByID(lib.Path, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "存档不存在",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s.zip", save.Name, time.Now().Format("20060102_150405"))
+	if _, err := s.streamSavesZip(c, filename, []SaveInfo{*save}, false, requestUsername(c), secret); err != nil {
+		s.addLog("export", fmt.Sprintf("导出存档失败: %s", save.Name), false, err.Error())
+		abortStream(c)
+		return
+	}
+
+	s.addLog("export", fmt.Sprintf("导出存档: %s", save.Name), true, "")
+}
+
+// BatchExport 批量导出存档。默认直接返回ZIP，mode=link 时返回一个有时效的签名下载链接，
+// 适合浏览器直接下载大量存档
+func (s *SaveService) BatchExport(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+	secret, ok := exportSecret(c)
+	if !ok {
+		return
+	}
+
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	if len(req.SaveIDs) == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请选择要导出的存档",
+		})
+		return
+	}
+
+	saves, ok := s.resolveExportSaves(c, lib.Path, req.SaveIDs)
+	if !ok {
+		return
+	}
+
+	if wantsAsync(c) {
+		exportedBy := requestUsername(c)
+		s.submitJob(c, "batch_export", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+			return s.runBatchExportJob(ctx, job, saves, exportedBy, secret)
+		})
+		return
+	}
+
+	if c.Query("mode") == "link" {
+		link, err := createDownloadLink(lib.Name, req.SaveIDs, requestUsername(c), secret != nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Success: false,
+				Error:   "生成下载链接失败: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, APIResponse{
+			Success: true,
+			Data:    link,
+		})
+		return
+	}
+
+	s.streamBatchExport(c, saves, requestUsername(c), secret)
+}
+
+// DownloadExport 通过签名链接下载批量导出的存档，不需要登录
+func (s *SaveService) DownloadExport(c *gin.Context) {
+	claims := &downloadClaims{}
+	_, err := jwt.ParseWithClaims(c.Query("token"), claims, func(token *jwt.Token) (interface{}, error) {
+		return downloadSigningKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		c.JSON(http.StatusForbidden, APIResponse{
+			Success: false,
+			Error:   "下载链接无效或已过期",
+		})
+		return
+	}
+
+	lib, ok := s.getLibrary(claims.Library)
+	if !ok {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("存档库不存在: %s", claims.Library),
+		})
+		return
+	}
+
+	saves, ok := s.resolveExportSaves(c, lib.Path, claims.SaveIDs)
+	if !ok {
+		return
+	}
+
+	// 链接生成后加密配置可能已被移除，此时不能退回到不加密的下载
+	var secret *archiveSecret
+	if claims.Encrypt {
+		if secret, err = requireEncryptionSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	s.streamBatchExport(c, saves, claims.Username, secret)
+}
+
+// createDownloadLink 为批量导出生成签名下载链接
+func createDownloadLink(library string, saveIDs []string, username string, encrypt bool) (gin.H, error) {
+	expiresAt := time.Now().Add(downloadLinkTTL)
+	claims := downloadClaims{
+		Library:  library,
+		SaveIDs:  saveIDs,
+		Username: username,
+		Encrypt:  encrypt,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(downloadSigningKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"url":       "/api/downloads/export?token=" + url.QueryEscape(token),
+		"expiresAt": expiresAt,
+	}, nil
+}
+
+// resolveExportSaves 按ID查找要导出的存档。任何一个存档不存在时不导出，返回每个存档的查找结果，
+// 避免下载到缺少存档的压缩包
+func (s *SaveService) resolveExportSaves(c *gin.Context, rootPath string, saveIDs []string) ([]SaveInfo, bool) {
+	saves := make([]SaveInfo, 0, len(saveIDs))
+	result := &BatchResult{Results: make([]BatchItemResult, 0, len(saveIDs))}
+	var missing []string
+	for _, id := range saveIDs {
+		save, err := s.getSaveByID(rootPath, id)
+		if err != nil {
+			missing = append(missing, id)
+			result.add(BatchItemResult{ID: id, Status: BatchStatusNotFound, Error: "存档不存在"})
+			continue
+		}
+		saves = append(saves, *save)
+		result.add(BatchItemResult{ID: id, Name: save.Name, Status: BatchStatusSkipped})
+	}
+
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "以下存档不存在: " + strings.Join(missing, ", "),
+			Data:    result,
+		})
+		return nil, false
+	}
+	return saves, true
+}
+
+// streamBatchExport 把多个存档写入同一个ZIP响应，每个存档一个目录，每个存档的结果写在压缩包根目录的结果文件中
+func (s *SaveService) streamBatchExport(c *gin.Context, saves []SaveInfo, exportedBy string, secret *archiveSecret) {
+	filename := fmt.Sprintf("stardew_saves_batch_%s.zip", time.Now().Format("20060102_150405"))
+	result, err := s.streamSavesZip(c, filename, saves, true, exportedBy, secret)
+	s.logBatchResult("batch_export", "批量导出存档", result)
+	if err != nil {
+		abortStream(c)
+	}
+}
+
+// runBatchExportJob 在后台把存档打包成任务的结果文件，完成后通过任务接口下载
+func (s *SaveService) runBatchExportJob(ctx context.Context, job *jobEntry, saves []SaveInfo, exportedBy string, secret *archiveSecret) (interface{}, error) {
+	files := 0
+	var size int64
+	for _, save := range saves {
+		n, bytes := directoryStats(save.Path)
+		files += n
+		size += bytes
+	}
+	job.setTotal(files, size)
+
+	filename := encryptedName(fmt.Sprintf("stardew_saves_batch_%s.zip", time.Now().Format("20060102_150405")), secret)
+	file, err := job.createArtifact(filename)
+	if err != nil {
+		return nil, fmt.Errorf("创建导出文件失败: %v", err)
+	}
+
+	var w io.Writer = file
+	var encrypter io.WriteCloser
+	if secret != nil {
+		if encrypter, err = newEncryptWriter(file, secret); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("创建导出文件失败: %v", err)
+		}
+		w = encrypter
+	}
+
+	result, err := s.writeSavesZip(ctx, w, saves, true, exportedBy, job)
+	if encrypter != nil && err == nil {
+		err = encrypter.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	s.logBatchResult("batch_export", "批量导出存档", result)
+	if err != nil {
+		return result, err
+	}
+
+	return gin.H{
+		"filename":  filename,
+		"download":  fmt.Sprintf("/api/jobs/%s/download", job.job.ID),
+		"succeeded": result.Succeeded,
+		"failed":    result.Failed,
+		"results":   result.Results,
+	}, nil
+}
+
+// streamSavesZip 边打包边写入响应。响应头发出后无法再返回错误信息，出错时由调用方中断连接，
+// 客户端会看到下载失败而不是一个内容缺失的压缩包
+func (s *SaveService) streamSavesZip(c *gin.Context, filename string, saves []SaveInfo, withDirs bool, exportedBy string, secret *archiveSecret) (*BatchResult, error) {
+	if secret == nil {
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+		c.Status(http.StatusOK)
+		return s.writeSavesZip(c.Request.Context(), c.Writer, saves, withDirs, exportedBy, noProgress{})
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", encryptedName(filename, secret)))
+	c.Status(http.StatusOK)
+	encrypter, err := newEncryptWriter(c.Writer, secret)
+	if err != nil {
+		return &BatchResult{}, err
+	}
+	result, err := s.writeSavesZip(c.Request.Context(), encrypter, saves, withDirs, exportedBy, noProgress{})
+	if err != nil {
+		return result, err
+	}
+	return result, encrypter.Close()
+}
+
+// abortStreamKey 标记需要中断连接的请求
+const abortStreamKey = "abort_stream"
+
+// abortStream 在响应已经开始写入后出错时调用，请求结束时由 abortStreamHandler 中断连接
+func abortStream(c *gin.Context) {
+	c.Set(abortStreamKey, true)
+	c.Abort()
+}
+
+// errStreamAborted 记录在被中断的请求上，访问日志中可以看到下载失败
+var errStreamAborted = errors.New("响应写入过程中出错，已中断连接")
+
+// abortStreamFlag 由 abortStreamHandler 放入请求的 context，请求结束后据此中断连接
+type abortStreamFlag struct {
+	aborted bool
+}
+
+type abortStreamContextKey struct{}
+
+// abortStreamMiddleware 把被标记的请求记为出错，并通知 abortStreamHandler 中断连接。
+// 它注册在 Logger 和 Recovery 之内，自身不 panic，访问日志照常记录这个请求
+func abortStreamMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		flag, _ := c.Request.Context().Value(abortStreamContextKey{}).(*abortStreamFlag)
+		c.Next()
+		if !c.GetBool(abortStreamKey) {
+			return
+		}
+		c.Error(errStreamAborted)
+		if flag == nil {
+			// 没有经过 abortStreamHandler 时只能在这里中断
+			panic(http.ErrAbortHandler)
+		}
+		flag.aborted = true
+	}
+}
+
+// abortStreamHandler 在 gin 处理完请求（包括写完访问日志）后，用 http.ErrAbortHandler 中断被标记的请求的连接，
+// 使客户端看到下载失败，而不是正常结束的残缺文件。gin 的 Recovery 会吞掉这个 panic，所以要在 gin 之外
+func abortStreamHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flag := &abortStreamFlag{}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), abortStreamContextKey{}, flag)))
+		if flag.aborted {
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// exportResultsFileName 多存档压缩包根目录中的导出结果文件，导入时不会被当作存档
+const exportResultsFileName = "stardew-export-results.json"
+
+// writeSavesZip 把存档及其导出清单打包写入 w，withDirs 为 true 时每个存档一个目录，
+// 并在根目录写入每个存档的导出结果，返回每个存档的结果。
+// 一个存档出错后压缩包已不完整，剩余的存档记为跳过
+func (s *SaveService) writeSavesZip(ctx context.Context, w io.Writer, saves []SaveInfo, withDirs bool, exportedBy string, progress progressReporter) (*BatchResult, error) {
+	result := &BatchResult{Results: make([]BatchItemResult, 0, len(saves))}
+
+	// 确定性模式下按存档名排序，相同的存档总是得到相同的压缩包
+	opts := s.zipOptions()
+	if opts.deterministic {
+		saves = append([]SaveInfo(nil), saves...)
+		sort.Slice(saves, func(i, j int) bool {
+			return saves[i].Name < saves[j].Name
+		})
+	}
+
+	zipWriter := opts.newZipWriter(w)
+	var err error
+	for _, save := range saves {
+		item := BatchItemResult{ID: save.ID, Name: save.Name}
+		if err != nil {
+			item.Status = BatchStatusSkipped
+			item.Error = "导出已中止"
+			result.add(item)
+			continue
+		}
+
+		dirName := ""
+		if withDirs {
+			dirName = save.Name
+		}
+		if err = s.writeSaveToZip(ctx, zipWriter, opts, save, dirName, exportedBy, progress); err != nil {
+			log.Printf("导出存档 %s 失败: %v", save.Name, err)
+			item.Status = BatchStatusFailed
+			item.Error = err.Error()
+		} else {
+			item.Status = BatchStatusExported
+		}
+		result.add(item)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if withDirs {
+		if err := writeZipJSON(zipWriter, opts, exportResultsFileName, result); err != nil {
+			return result, err
+		}
+	}
+
+	// 目录区写入失败时整个压缩包无效
+	if err := zipWriter.Close(); err != nil {
+		for i := range result.Results {
+			result.Results[i].Status = BatchStatusFailed
+			result.Results[i].Error = err.Error()
+		}
+		result.Succeeded, result.Failed = 0, len(result.Results)
+		return result, err
+	}
+	return result, nil
+}
+
+// writeZipJSON 把 v 编码为 JSON 写入压缩包中的 name
+func writeZipJSON(zipWriter *zip.Writer, opts zipOptions, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	writer, err := zipWriter.CreateHeader(opts.fileHeader(name, time.Now()))
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbortStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	r := gin.New()
+	r.Use(gin.LoggerWithWriter(&logs), gin.Recovery(), abortStreamMiddleware())
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "application/zip")
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Write(bytes.Repeat([]byte("x"), 1024))
+		c.Writer.Flush()
+		if c.Query("fail") != "" {
+			abortStream(c)
+		}
+	})
+	srv := httptest.NewServer(abortStreamHandler(r))
+	defer srv.Close()
+
+	// 出错的下载在客户端表现为读取失败，访问日志中记录了这个请求和错误
+	resp, err := http.Get(srv.URL + "/stream?fail=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Error("aborted download was read without error")
+	}
+	if !strings.Contains(logs.String(), "/stream?fail=1") || !strings.Contains(logs.String(), errStreamAborted.Error()) {
+		t.Errorf("access log does not contain the aborted request: %q", logs.String())
+	}
+
+	// 正常的下载不受影响
+	logs.Reset()
+	resp, err = http.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(data) != 1024 {
+		t.Errorf("download: %d bytes, %v", len(data), err)
+	}
+	if strings.Contains(logs.String(), errStreamAborted.Error()) {
+		t.Errorf("successful download logged as aborted: %q", logs.String())
+	}
+}
@@ -0,0 +1,798 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 存档历史：每个存档库一个 git 裸仓库，每个存档目录一个分支，每次快照是分支上的一个提交，
+// 提交用游戏内日期打标签。快照使用临时索引直接从存档目录生成，不需要工作区
+const (
+	defaultHistoryDir = "./history"
+	maxHistoryPatch   = 1 << 20
+	defaultHistoryLog = 50
+	maxHistoryLog     = 500
+)
+
+// emptyTreeHash git 的空树，用于和第一个快照比较
+const emptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// 快照原因
+const (
+	historyReasonManual        = "manual"
+	historyReasonBackup        = "backup"
+	historyReasonBeforeRestore = "before_restore"
+	historyReasonRestore       = "restore"
+)
+
+// ErrGitNotInstalled 服务器上没有 git
+var ErrGitNotInstalled = errors.New("服务器未安装 git，无法使用存档历史")
+
+// xmlWordDiffRegex 按 XML 标签和标签之间的文本拆分单词，存档通常只有一行，按行比较没有意义
+const xmlWordDiffRegex = `<[^>]*>|[^<]+`
+
+var historyHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,64}$`)
+
+// historyDir 返回历史仓库目录：环境变量 SAVE_HISTORY_DIR > ./history
+func historyDir() string {
+	if dir := os.Getenv("SAVE_HISTORY_DIR"); dir != "" {
+		return filepath.Clean(dir)
+	}
+	return defaultHistoryDir
+}
+
+// historyEnabled 删除或覆盖存档前是否自动快照：环境变量 SAVE_HISTORY > 配置文件 historyEnabled
+func (s *SaveService) historyEnabled() bool {
+	if value := os.Getenv("SAVE_HISTORY"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			return enabled
+		}
+		log.Printf("无效的 SAVE_HISTORY: %s", value)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.configHistoryEnabled
+}
+
+// gitRepo 存档库的历史仓库
+type gitRepo struct {
+	dir string
+}
+
+// historyRepo 打开存档库的历史仓库，不存在时创建
+func historyRepo(libName string) (*gitRepo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrGitNotInstalled
+	}
+
+	// 命令中会切换工作区，仓库、索引和工作区都使用绝对路径
+	dir, err := filepath.Abs(filepath.Join(historyDir(), libName+".git"))
+	if err != nil {
+		return nil, err
+	}
+	repo := &gitRepo{dir: dir}
+	if _, err := os.Stat(filepath.Join(repo.dir, "HEAD")); err == nil {
+		return repo, nil
+	}
+	if err := os.MkdirAll(repo.dir, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := repo.git(context.Background(), nil, "init", "--quiet", "--bare"); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// command 创建 git 命令，不读取系统和用户的 git 配置
+func (r *gitRepo) command(ctx context.Context, env []string, args ...string) *exec.Cmd {
+	base := []string{"--git-dir=" + r.dir, "-c", "core.quotepath=false", "-c", "core.autocrlf=false"}
+	cmd := exec.CommandContext(ctx, "git", append(base, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, env...)
+	return cmd
+}
+
+// git 运行 git 命令并返回标准输出，失败时错误中包含 git 的错误输出
+func (r *gitRepo) git(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := r.command(ctx, env, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", gitSubcommand(args), message)
+	}
+	return string(out), nil
+}
+
+// gitLimited 运行 git 命令，最多读取 limit 字节的输出，超出时返回 truncated
+func (r *gitRepo) gitLimited(ctx context.Context, limit int, args ...string) (string, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := r.command(ctx, nil, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", false, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", false, err
+	}
+
+	out, err := io.ReadAll(io.LimitReader(stdout, int64(limit)+1))
+	truncated := len(out) > limit
+	if truncated {
+		out = out[:limit]
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	if err == nil && !truncated && waitErr != nil {
+		err = fmt.Errorf("git %s: %s", gitSubcommand(args), strings.TrimSpace(stderr.String()))
+	}
+	return string(out), truncated, err
+}
+
+// gitSubcommand 返回参数中的子命令，用于错误信息
+func gitSubcommand(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+// revParse 解析提交，不存在时返回空字符串
+func (r *gitRepo) revParse(ctx context.Context, rev string) string {
+	out, err := r.git(ctx, nil, "rev-parse", "--quiet", "--verify", rev)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// historyBranch 把存档目录名编码成分支名：保留字母、数字、下划线、连字符和非 ASCII 字符，其余字节用 %XX 表示
+func historyBranch(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 0x80 || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '_' || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// historySaveName 把分支名还原成存档目录名
+func historySaveName(branch string) string {
+	if name, err := url.PathUnescape(branch); err == nil {
+		return name
+	}
+	return branch
+}
+
+// historyGameDate 游戏内日期，用作标签名
+func historyGameDate(save SaveInfo) string {
+	if save.Year <= 0 || save.Day <= 0 || save.Season == "" {
+		return ""
+	}
+	return fmt.Sprintf("y%d-%s-%d", save.Year, historyBranch(strings.ToLower(save.Season)), save.Day)
+}
+
+// historyReasonText 快照原因的说明
+func historyReasonText(reason string) string {
+	switch reason {
+	case historyReasonManual:
+		return "手动快照"
+	case historyReasonBackup:
+		return "删除或覆盖前的自动快照"
+	case historyReasonBeforeRestore:
+		return "恢复历史前的快照"
+	case historyReasonRestore:
+		return "恢复历史"
+	}
+	return reason
+}
+
+// snapshotSave 把存档目录的当前内容提交到历史仓库。内容和最新的快照相同时不创建新提交，返回最新的快照
+func (s *SaveService) snapshotSave(ctx context.Context, libName string, save SaveInfo, reason, author, note string) (*HistoryEntry, bool, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	repo, err := historyRepo(libName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	indexEnv, cleanup, err := historyIndex()
+	if err != nil {
+		return nil, false, err
+	}
+	defer cleanup()
+	workTree, err := filepath.Abs(save.Path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	branch := historyBranch(filepath.Base(save.Path))
+	ref := "refs/heads/" + branch
+	parent := repo.revParse(ctx, ref+"^{commit}")
+
+	if _, err := repo.git(ctx, indexEnv, "--work-tree="+workTree, "add", "--all", "--force", "--", "."); err != nil {
+		return nil, false, err
+	}
+	tree, err := repo.git(ctx, indexEnv, "write-tree")
+	if err != nil {
+		return nil, false, err
+	}
+	tree = strings.TrimSpace(tree)
+
+	if parent != "" && repo.revParse(ctx, parent+"^{tree}") == tree {
+		entries, err := s.historyLog(ctx, repo, branch, parent, 1)
+		if err != nil || len(entries) == 0 {
+			return nil, false, err
+		}
+		return &entries[0], false, nil
+	}
+
+	gameDate := historyGameDate(save)
+	subject := historyReasonText(reason)
+	if save.Year > 0 {
+		subject = fmt.Sprintf("%s: 第 %d 年 %s 第 %d 天", subject, save.Year, save.Season, save.Day)
+	}
+	message := subject + "\n\n"
+	if note != "" {
+		message += note + "\n\n"
+	}
+	message += fmt.Sprintf("Save: %s\nFarm: %s\nPlayer: %s\nMoney: %d\nGame-Date: %s\nReason: %s\n",
+		filepath.Base(save.Path), save.FarmName, save.PlayerName, save.Money, gameDate, reason)
+
+	if author == "" {
+		author = "system"
+	}
+	identity := []string{
+		"GIT_AUTHOR_NAME=" + author, "GIT_AUTHOR_EMAIL=" + author + "@stardew-save-manager",
+		"GIT_COMMITTER_NAME=stardew-save-manager", "GIT_COMMITTER_EMAIL=stardew-save-manager@localhost",
+	}
+	args := []string{"commit-tree", tree, "-m", message}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	commit, err := repo.git(ctx, identity, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	commit = strings.TrimSpace(commit)
+
+	// 旧值为空表示分支必须不存在，避免覆盖并发创建的提交
+	if _, err := repo.git(ctx, nil, "update-ref", "-m", "snapshot", ref, commit, parent); err != nil {
+		return nil, false, err
+	}
+
+	// 同一天有多个快照时标签加上序号
+	if gameDate != "" {
+		tag := "refs/tags/" + branch + "/" + gameDate
+		for i := 2; i < 100; i++ {
+			if _, err := repo.git(ctx, nil, "update-ref", tag, commit, ""); err == nil {
+				break
+			}
+			tag = fmt.Sprintf("refs/tags/%s/%s-%d", branch, gameDate, i)
+		}
+	}
+
+	entries, err := s.historyLog(ctx, repo, branch, commit, 1)
+	if err != nil || len(entries) == 0 {
+		return nil, true, err
+	}
+	return &entries[0], true, nil
+}
+
+// autoSnapshot 开启了自动历史时，在删除或覆盖存档前快照，失败只记录日志
+func (s *SaveService) autoSnapshot(savePath string) {
+	if !s.historyEnabled() {
+		return
+	}
+
+	for _, lib := range s.listLibraries() {
+		root, err := resolvePath(lib.Path)
+		if err != nil {
+			continue
+		}
+		resolved, err := resolvePath(savePath)
+		if err != nil || !isWithinDir(root, resolved) || filepath.Dir(resolved) != root {
+			continue
+		}
+
+		save := s.parseSaveDirectory(lib.Path, savePath)
+		if _, _, err := s.snapshotSave(context.Background(), lib.Name, save, historyReasonBackup, "", ""); err != nil {
+			log.Printf("存档历史快照失败: %s: %v", save.Name, err)
+		}
+		return
+	}
+}
+
+// historyLog 读取分支的快照列表，新的在前
+func (s *SaveService) historyLog(ctx context.Context, repo *gitRepo, branch, rev string, limit int) ([]HistoryEntry, error) {
+	out, err := repo.git(ctx, nil, "log", "-n", strconv.Itoa(limit),
+		"--format=%H%x1f%an%x1f%aI%x1f%s%x1f%(trailers:only,unfold)%x1e", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string][]string)
+	refs, err := repo.git(ctx, nil, "for-each-ref", "--format=%(objectname) %(refname)", "refs/tags/"+branch)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(refs), "\n") {
+		commit, ref, ok := strings.Cut(line, " ")
+		if ok {
+			tags[commit] = append(tags[commit], strings.TrimPrefix(ref, "refs/tags/"+branch+"/"))
+		}
+	}
+
+	entries := make([]HistoryEntry, 0)
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) < 5 {
+			continue
+		}
+		entry := HistoryEntry{
+			Commit:  fields[0],
+			Author:  fields[1],
+			Message: fields[3],
+			Tags:    tags[fields[0]],
+		}
+		entry.CreatedAt, _ = time.Parse(time.RFC3339, fields[2])
+		for _, line := range strings.Split(fields[4], "\n") {
+			key, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Reason":
+				entry.Reason = value
+			case "Game-Date":
+				entry.GameDate = value
+			case "Money":
+				entry.Money, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// resolveHistoryRevision 把提交哈希或标签解析为分支上的提交，不接受分支之外的提交
+func resolveHistoryRevision(ctx context.Context, repo *gitRepo, branch, rev string) (string, error) {
+	var commit string
+	switch {
+	case historyHashPattern.MatchString(rev):
+		commit = repo.revParse(ctx, rev+"^{commit}")
+	case rev != "" && !strings.Contains(rev, "..") && !strings.HasPrefix(rev, "-"):
+		commit = repo.revParse(ctx, "refs/tags/"+branch+"/"+rev+"^{commit}")
+	}
+	if commit == "" {
+		return "", fmt.Errorf("快照不存在: %s", rev)
+	}
+	if _, err := repo.git(ctx, nil, "merge-base", "--is-ancestor", commit, "refs/heads/"+branch); err != nil {
+		return "", fmt.Errorf("快照不属于该存档: %s", rev)
+	}
+	return commit, nil
+}
+
+// historySave 返回请求中的存档目录名和路径。存档已被删除时把 ID 当作目录名，仍然可以查看和恢复历史
+func (s *SaveService) historySave(c *gin.Context) (SaveLibrary, string, string, bool) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return SaveLibrary{}, "", "", false
+	}
+
+	id := c.Param("id")
+	if save, err := s.getSaveByID(lib.Path, id); err == nil {
+		return lib, filepath.Base(save.Path), save.Path, true
+	}
+	if !isValidSaveName(id) {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "存档不存在",
+		})
+		return SaveLibrary{}, "", "", false
+	}
+	return lib, id, filepath.Join(lib.Path, id), true
+}
+
+// openHistory 打开存档库的历史仓库，存档没有历史时直接写入错误响应
+func (s *SaveService) openHistory(c *gin.Context, lib SaveLibrary, name string) (*gitRepo, bool) {
+	repo, err := historyRepo(lib.Name)
+	if err != nil {
+		respondHistoryError(c, err)
+		return nil, false
+	}
+	if repo.revParse(c.Request.Context(), "refs/heads/"+historyBranch(name)+"^{commit}") == "" {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "存档没有历史记录",
+		})
+		return nil, false
+	}
+	return repo, true
+}
+
+// respondHistoryError 写入历史操作的错误响应
+func respondHistoryError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrGitNotInstalled) {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
+
+// GetHistorySaves 列出存档库中有历史记录的存档，包括已被删除的存档
+func (s *SaveService) GetHistorySaves(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	repo, err := historyRepo(lib.Name)
+	if err != nil {
+		respondHistoryError(c, err)
+		return
+	}
+	out, err := repo.git(c.Request.Context(), nil, "for-each-ref", "--sort=-committerdate",
+		"--format=%(refname:strip=2)%09%(objectname)%09%(committerdate:iso-strict)", "refs/heads")
+	if err != nil {
+		respondHistoryError(c, err)
+		return
+	}
+
+	saves := make([]HistorySave, 0)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		save := HistorySave{Name: historySaveName(fields[0]), Head: fields[1]}
+		save.UpdatedAt, _ = time.Parse(time.RFC3339, fields[2])
+		if info, err := os.Stat(filepath.Join(lib.Path, save.Name)); err == nil && info.IsDir() {
+			save.Exists = true
+		}
+		saves = append(saves, save)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    saves,
+	})
+}
+
+// GetSaveHistory 列出存档的快照，新的在前，limit 默认 50
+func (s *SaveService) GetSaveHistory(c *gin.Context) {
+	lib, name, _, ok := s.historySave(c)
+	if !ok {
+		return
+	}
+	repo, ok := s.openHistory(c, lib, name)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultHistoryLog)))
+	if err != nil || limit <= 0 || limit > maxHistoryLog {
+		limit = defaultHistoryLog
+	}
+
+	branch := historyBranch(name)
+	entries, err := s.historyLog(c.Request.Context(), repo, branch, "refs/heads/"+branch, limit)
+	if err != nil {
+		respondHistoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
+
+// CreateSnapshot 把存档的当前内容提交到历史，内容没有变化时不创建新快照
+func (s *SaveService) CreateSnapshot(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	save, err := s.getSaveByID(lib.Path, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "存档不存在",
+		})
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	entry, created, err := s.snapshotSave(c.Request.Context(), lib.Name, *save, historyReasonManual, requestUsername(c), req.Message)
+	if err != nil {
+		s.addLog("history", fmt.Sprintf("存档快照失败: %s", save.Name), false, err.Error())
+		respondHistoryError(c, err)
+		return
+	}
+
+	message := "存档没有变化，未创建新快照"
+	if created {
+		message = "快照已创建"
+		s.addLog("history", fmt.Sprintf("存档快照: %s", save.Name), true, entry.Commit)
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    entry,
+	})
+}
+
+// GetHistoryDiff 比较两次快照。to 默认为最新的快照，from 默认为 to 的上一次快照；
+// word=true 时按 XML 标签和文本比较，适合只有一行的存档文件
+func (s *SaveService) GetHistoryDiff(c *gin.Context) {
+	lib, name, _, ok := s.historySave(c)
+	if !ok {
+		return
+	}
+	repo, ok := s.openHistory(c, lib, name)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	branch := historyBranch(name)
+	to := "refs/heads/" + branch
+	if rev := c.Query("to"); rev != "" {
+		commit, err := resolveHistoryRevision(ctx, repo, branch, rev)
+		if err != nil {
+			c.JSON(http.StatusNotFound, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		to = commit
+	}
+	to = repo.revParse(ctx, to+"^{commit}")
+
+	from := repo.revParse(ctx, to+"^1")
+	if from == "" {
+		from = emptyTreeHash
+	}
+	if rev := c.Query("from"); rev != "" {
+		commit, err := resolveHistoryRevision(ctx, repo, branch, rev)
+		if err != nil {
+			c.JSON(http.StatusNotFound, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		from = commit
+	}
+
+	numstat, err := repo.git(ctx, nil, "diff", "--numstat", "--no-renames", from, to, "--")
+	if err != nil {
+		respondHistoryError(c, err)
+		return
+	}
+	diff := HistoryDiff{From: from, To: to, Files: make([]HistoryDiffFile, 0)}
+	for _, line := range strings.Split(strings.TrimSpace(numstat), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := HistoryDiffFile{Path: fields[2], Binary: fields[0] == "-"}
+		file.Additions, _ = strconv.Atoi(fields[0])
+		file.Deletions, _ = strconv.Atoi(fields[1])
+		diff.Files = append(diff.Files, file)
+	}
+
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--no-renames"}
+	if word, _ := strconv.ParseBool(c.Query("word")); word {
+		args = append(args, "--word-diff=plain", "--word-diff-regex="+xmlWordDiffRegex)
+	}
+	args = append(args, from, to, "--")
+	diff.Patch, diff.Truncated, err = repo.gitLimited(ctx, maxHistoryPatch, args...)
+	if err != nil {
+		respondHistoryError(c, err)
+		return
+	}
+
+	// 两个版本都有主存档文件时按游戏内容比较，第一次快照没有可比较的版本
+	if from != emptyTreeHash {
+		diff.Changes = s.diffHistoryVersions(ctx, repo, from, to, name)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    diff,
+	})
+}
+
+// diffHistoryVersions 按游戏内容比较两次快照的主存档文件，无法读取或解析时返回 nil
+func (s *SaveService) diffHistoryVersions(ctx context.Context, repo *gitRepo, from, to, name string) *SaveDiff {
+	docs := make([]*saveDocument, 0, 2)
+	for _, commit := range []string{from, to} {
+		data, err := readHistorySaveFile(ctx, repo, commit, name)
+		if err != nil {
+			return nil
+		}
+		doc, err := parseSaveDocument(data)
+		if err != nil {
+			return nil
+		}
+		docs = append(docs, doc)
+	}
+
+	diff := s.diffSaveDocuments(docs[0], docs[1])
+	diff.Base.Label = "快照 " + from[:12]
+	diff.Target.Label = "快照 " + to[:12]
+	return diff
+}
+
+// RestoreFromHistory 把存档恢复到某次快照。存档仍然存在时先快照当前内容并按覆盖规则备份，
+// 已被删除的存档会被重新创建。恢复后再快照一次，历史中会记录这次恢复
+func (s *SaveService) RestoreFromHistory(c *gin.Context) {
+	lib, name, targetPath, ok := s.historySave(c)
+	if !ok {
+		return
+	}
+	repo, ok := s.openHistory(c, lib, name)
+	if !ok {
+		return
+	}
+
+	var req HistoryRestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	branch := historyBranch(name)
+	commit, err := resolveHistoryRevision(ctx, repo, branch, req.Commit)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	// 存档可能已被删除，检查存档库目录
+	if !s.isAllowedPath(lib.Path) {
+		c.JSON(http.StatusForbidden, APIResponse{
+			Success: false,
+			Error:   "存档路径不在允许的目录范围内",
+		})
+		return
+	}
+
+	username := requestUsername(c)
+	fail := func(format string, args ...interface{}) {
+		err := fmt.Errorf(format, args...)
+		s.addLog("history", fmt.Sprintf("恢复存档历史失败: %s", name), false, err.Error())
+		respondHistoryError(c, err)
+	}
+
+	if _, err := os.Stat(targetPath); err == nil {
+		current := s.parseSaveDirectory(lib.Path, targetPath)
+		if _, _, err := s.snapshotSave(ctx, lib.Name, current, historyReasonBeforeRestore, username, ""); err != nil {
+			fail("快照当前存档失败: %v", err)
+			return
+		}
+	}
+	_, backupPath, err := s.checkSaveConflict(targetPath, true, true)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+
+	// 检出到同一文件系统上的暂存目录，校验通过后再替换
+	stagingPath := filepath.Join(lib.Path, stagingPrefix+"history_"+uuid.New().String())
+	defer os.RemoveAll(stagingPath)
+	if err := s.checkoutSnapshot(ctx, repo, commit, stagingPath); err != nil {
+		fail("检出快照失败: %v", err)
+		return
+	}
+	if err := s.validateStagedSave(stagingPath, name); err != nil {
+		fail("快照中的存档无效: %v", err)
+		return
+	}
+	if err := swapDirectory(stagingPath, targetPath); err != nil {
+		fail("替换存档失败: %v", err)
+		return
+	}
+
+	restored := s.parseSaveDirectory(lib.Path, targetPath)
+	if _, _, err := s.snapshotSave(ctx, lib.Name, restored, historyReasonRestore, username, "恢复到 "+commit); err != nil {
+		log.Printf("存档历史快照失败: %s: %v", name, err)
+	}
+
+	s.addLog("history", fmt.Sprintf("恢复存档历史: %s", name), true, commit)
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "存档已恢复",
+		Data: gin.H{
+			"commit":     commit,
+			"save":       restored,
+			"backupPath": backupPath,
+		},
+	})
+}
+
+// historyIndex 创建临时索引，返回需要传给 git 的环境变量和清理函数
+func historyIndex() ([]string, func(), error) {
+	tempDir, err := newTempDir("history_")
+	if err != nil {
+		return nil, nil, err
+	}
+	absDir, err := filepath.Abs(tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, nil, err
+	}
+	return []string{"GIT_INDEX_FILE=" + filepath.Join(absDir, "index")}, func() { os.RemoveAll(tempDir) }, nil
+}
+
+// checkoutSnapshot 把快照中的文件写入 targetDir
+func (s *SaveService) checkoutSnapshot(ctx context.Context, repo *gitRepo, commit, targetDir string) error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	indexEnv, cleanup, err := historyIndex()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	absTarget, err := filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := repo.git(ctx, indexEnv, "read-tree", commit); err != nil {
+		return err
+	}
+	_, err = repo.git(ctx, indexEnv, "--work-tree="+absTarget, "checkout-index", "--all", "--force")
+	return err
+}
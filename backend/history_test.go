@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newHistoryTestService 创建只有一个存档库的服务，历史仓库、临时目录和备份目录都在测试目录中。
+// 存档库中有 Farm_200，内容为 diffBase16Save。没有 git 时跳过测试
+func newHistoryTestService(t *testing.T) (*SaveService, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := chdirTemp(t)
+	t.Setenv("SAVE_HISTORY_DIR", filepath.Join(dir, "history"))
+	t.Setenv("SAVE_HISTORY", "false")
+
+	root := filepath.Join(dir, "saves")
+	writeTestSave(t, root, "Farm_200", diffBase16Save)
+	s := newRootedService(t, root)
+	s.libraries[defaultLibraryName] = SaveLibrary{Name: defaultLibraryName, Path: root}
+	return s, root
+}
+
+// snapshotTestSave 快照存档目录的当前内容
+func snapshotTestSave(t *testing.T, s *SaveService, root, name, reason string) (*HistoryEntry, bool) {
+	t.Helper()
+	save := s.parseSaveDirectory(root, filepath.Join(root, name))
+	entry, created, err := s.snapshotSave(context.Background(), defaultLibraryName, save, reason, "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry, created
+}
+
+func openTestHistory(t *testing.T) *gitRepo {
+	t.Helper()
+	repo, err := historyRepo(defaultLibraryName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// historyRequest 调用历史接口，返回状态码和 data 字段
+func historyRequest(t *testing.T, s *SaveService, method, target string, body interface{}, data interface{}) (int, APIResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/saves/:id/history", s.GetSaveHistory)
+	r.GET("/saves/:id/history/diff", s.GetHistoryDiff)
+	r.POST("/saves/:id/history/restore", s.RestoreFromHistory)
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	resp := struct {
+		APIResponse
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v: %s", err, w.Body)
+	}
+	if data != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			t.Fatalf("decode data: %v: %s", err, resp.Data)
+		}
+	}
+	return w.Code, resp.APIResponse
+}
+
+func TestHistoryBranch(t *testing.T) {
+	tests := []struct {
+		name, branch string
+	}{
+		{"Farm_123456789", "Farm_123456789"},
+		{"My-Farm_1", "My-Farm_1"},
+		{"农场_1", "农场_1"},
+		{"Farm.1", "Farm%2E1"},
+		{"a b/c", "a%20b%2Fc"},
+		{"100%", "100%25"},
+	}
+	for _, tt := range tests {
+		if got := historyBranch(tt.name); got != tt.branch {
+			t.Errorf("historyBranch(%q) = %q, want %q", tt.name, got, tt.branch)
+		}
+		if got := historySaveName(tt.branch); got != tt.name {
+			t.Errorf("historySaveName(%q) = %q, want %q", tt.branch, got, tt.name)
+		}
+	}
+}
+
+func TestSnapshotSave(t *testing.T) {
+	s, root := newHistoryTestService(t)
+	savePath := filepath.Join(root, "Farm_200")
+
+	first, created := snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	if !created {
+		t.Fatal("first snapshot was not created")
+	}
+	if first.Reason != historyReasonManual || first.GameDate != "y1-spring-1" || first.Money != 500 || first.Author != "alice" {
+		t.Errorf("first snapshot = %+v", first)
+	}
+	if strings.Join(first.Tags, ",") != "y1-spring-1" {
+		t.Errorf("first snapshot tags = %v", first.Tags)
+	}
+
+	// 内容没有变化时返回最新的快照
+	again, created := snapshotTestSave(t, s, root, "Farm_200", historyReasonBackup)
+	if created || again.Commit != first.Commit {
+		t.Errorf("unchanged snapshot: created = %v, commit = %s, want %s", created, again.Commit, first.Commit)
+	}
+
+	// 同一天的第二个快照标签加上序号
+	if err := os.WriteFile(filepath.Join(savePath, "Farm_200_old"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, created := snapshotTestSave(t, s, root, "Farm_200", historyReasonBackup)
+	if !created || strings.Join(second.Tags, ",") != "y1-spring-1-2" {
+		t.Errorf("second snapshot: created = %v, tags = %v", created, second.Tags)
+	}
+
+	if err := os.WriteFile(filepath.Join(savePath, "Farm_200"), []byte(diffTarget16Save), 0644); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	if third.GameDate != "y1-spring-2" || third.Money != 800 {
+		t.Errorf("third snapshot = %+v", third)
+	}
+
+	repo := openTestHistory(t)
+	ctx := context.Background()
+	if parent := repo.revParse(ctx, third.Commit+"^1"); parent != second.Commit {
+		t.Errorf("parent of the third snapshot = %s, want %s", parent, second.Commit)
+	}
+	if parent := repo.revParse(ctx, first.Commit+"^1"); parent != "" {
+		t.Errorf("first snapshot has parent %s", parent)
+	}
+
+	entries, err := s.historyLog(ctx, repo, "Farm_200", "refs/heads/Farm_200", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, entry := range entries {
+		reasons = append(reasons, entry.Reason)
+	}
+	if got := strings.Join(reasons, ","); got != "manual,backup,manual" {
+		t.Errorf("log reasons = %s, want newest first", got)
+	}
+	if !strings.HasPrefix(entries[0].Message, "手动快照: 第 1 年 spring 第 2 天") {
+		t.Errorf("subject = %q", entries[0].Message)
+	}
+}
+
+func TestResolveHistoryRevision(t *testing.T) {
+	s, root := newHistoryTestService(t)
+	writeTestSave(t, root, "Farm_300", diffBase16Save)
+	entry, _ := snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	other, _ := snapshotTestSave(t, s, root, "Farm_300", historyReasonManual)
+	repo := openTestHistory(t)
+	ctx := context.Background()
+
+	for _, rev := range []string{entry.Commit, entry.Commit[:12], "y1-spring-1"} {
+		commit, err := resolveHistoryRevision(ctx, repo, "Farm_200", rev)
+		if err != nil || commit != entry.Commit {
+			t.Errorf("resolve %q = %s, %v, want %s", rev, commit, err, entry.Commit)
+		}
+	}
+
+	// 两个存档内容相同，但提交属于另一个分支
+	if other.Commit == entry.Commit {
+		t.Fatal("snapshots of different saves share a commit")
+	}
+	for _, rev := range []string{other.Commit, "y1-spring-2", "../Farm_300/y1-spring-1", "--all", ""} {
+		if _, err := resolveHistoryRevision(ctx, repo, "Farm_200", rev); err == nil {
+			t.Errorf("resolve %q succeeded", rev)
+		}
+	}
+}
+
+func TestCheckoutSnapshot(t *testing.T) {
+	s, root := newHistoryTestService(t)
+	entry, _ := snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	os.WriteFile(filepath.Join(root, "Farm_200", "Farm_200"), []byte(diffTarget16Save), 0644)
+	snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	repo := openTestHistory(t)
+	ctx := context.Background()
+
+	target := filepath.Join(t.TempDir(), "checkout")
+	if err := s.checkoutSnapshot(ctx, repo, entry.Commit, target); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(dirNames(t, target), ","); got != "Farm_200,Farm_200_old,SaveGameInfo" {
+		t.Errorf("checked out %s", got)
+	}
+	data, err := os.ReadFile(filepath.Join(target, "Farm_200"))
+	if err != nil || string(data) != diffBase16Save {
+		t.Errorf("checked out main file differs from the snapshot: %v", err)
+	}
+
+	data, err = readHistorySaveFile(ctx, repo, entry.Commit, "Farm_200")
+	if err != nil || string(data) != diffBase16Save {
+		t.Errorf("readHistorySaveFile: %d bytes, %v", len(data), err)
+	}
+	if entries, _ := os.ReadDir("temp"); len(entries) != 0 {
+		t.Errorf("temporary indexes left behind: %v", entries)
+	}
+}
+
+func TestGetHistoryDiff(t *testing.T) {
+	s, root := newHistoryTestService(t)
+	snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	os.WriteFile(filepath.Join(root, "Farm_200", "Farm_200"), []byte(diffTarget16Save), 0644)
+	snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+
+	var diff HistoryDiff
+	status, resp := historyRequest(t, s, http.MethodGet, "/saves/Farm_200/history/diff", nil, &diff)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].Path != "Farm_200" {
+		t.Errorf("files = %+v, want only the main file", diff.Files)
+	}
+	if !strings.Contains(diff.Patch, "<money>800</money>") {
+		t.Error("patch does not contain the new money")
+	}
+	if diff.Changes == nil || diff.Changes.Identical || len(diff.Changes.Farmhands) != 2 {
+		t.Errorf("changes = %+v", diff.Changes)
+	}
+
+	// 第一次快照和空树比较，没有内容差异
+	diff = HistoryDiff{}
+	status, _ = historyRequest(t, s, http.MethodGet, "/saves/Farm_200/history/diff?to=y1-spring-1", nil, &diff)
+	if status != http.StatusOK || diff.From != emptyTreeHash || diff.Changes != nil || len(diff.Files) != 3 {
+		t.Errorf("first snapshot diff: status = %d, from = %s, files = %+v", status, diff.From, diff.Files)
+	}
+
+	status, _ = historyRequest(t, s, http.MethodGet, "/saves/Farm_200/history/diff?from=y9-winter-28", nil, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("unknown tag: status = %d, want 404", status)
+	}
+}
+
+func TestRestoreFromHistory(t *testing.T) {
+	s, root := newHistoryTestService(t)
+	savePath := filepath.Join(root, "Farm_200")
+	first, _ := snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+	os.WriteFile(filepath.Join(savePath, "Farm_200"), []byte(diffTarget16Save), 0644)
+	snapshotTestSave(t, s, root, "Farm_200", historyReasonManual)
+
+	var data struct {
+		Commit     string `json:"commit"`
+		BackupPath string `json:"backupPath"`
+	}
+	status, resp := historyRequest(t, s, http.MethodPost, "/saves/Farm_200/history/restore", HistoryRestoreRequest{Commit: "y1-spring-1"}, &data)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, error = %s", status, resp.Error)
+	}
+	if data.Commit != first.Commit {
+		t.Errorf("restored commit = %s, want %s", data.Commit, first.Commit)
+	}
+	if got := readMainFile(t, savePath); got != diffBase16Save {
+		t.Error("save was not restored to the first snapshot")
+	}
+	if _, err := os.Stat(data.BackupPath); data.BackupPath == "" || err != nil {
+		t.Errorf("backup of the replaced save = %q: %v", data.BackupPath, err)
+	}
+	if names := dirNames(t, root); len(names) != 1 {
+		t.Errorf("library contains %v after restore", names)
+	}
+
+	// 恢复前的内容已经快照过，只新增一个恢复快照
+	var entries []HistoryEntry
+	historyRequest(t, s, http.MethodGet, "/saves/Farm_200/history", nil, &entries)
+	if len(entries) != 3 || entries[0].Reason != historyReasonRestore || entries[0].GameDate != "y1-spring-1" {
+		t.Errorf("history after restore = %+v", entries)
+	}
+
+	// 已被删除的存档会被重新创建
+	if err := os.RemoveAll(savePath); err != nil {
+		t.Fatal(err)
+	}
+	data.BackupPath = ""
+	status, resp = historyRequest(t, s, http.MethodPost, "/saves/Farm_200/history/restore", HistoryRestoreRequest{Commit: first.Commit[:12]}, &data)
+	if status != http.StatusOK || data.BackupPath != "" {
+		t.Fatalf("restore deleted save: status = %d, error = %s, backup = %q", status, resp.Error, data.BackupPath)
+	}
+	if got := readMainFile(t, savePath); got != diffBase16Save {
+		t.Error("deleted save was not recreated")
+	}
+
+	tests := []struct {
+		name, target string
+		commit       string
+		status       int
+	}{
+		{"unknown snapshot", "/saves/Farm_200/history/restore", "y9-winter-28", http.StatusNotFound},
+		{"save without history", "/saves/Farm_300/history/restore", "y1-spring-1", http.StatusNotFound},
+		{"invalid save name", "/saves/.staging_x/history/restore", "y1-spring-1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		status, _ := historyRequest(t, s, http.MethodPost, tt.target, HistoryRestoreRequest{Commit: tt.commit}, nil)
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// archiveSave 压缩包中的一个存档目录
+type archiveSave struct {
+	Prefix string // 存档在压缩包中的目录前缀（以 / 结尾），文件位于根目录时为空
+	Name   string // 导入后的存档目录名
+}
+
+// detectZipSaves 找出压缩包中的所有存档目录。目录中存在与目录同名的主存档文件或导出清单即视为一个存档，
+// 这也是批量导出的格式；一个都找不到时按单个存档处理
+func detectZipSaves(files []*zip.File, zipPath string) ([]archiveSave, error) {
+	var saves []archiveSave
+	for _, file := range files {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		dir, name := path.Split(cleanArchiveName(file.Name))
+		if dir == "" {
+			continue
+		}
+		// 主存档文件缺失时仍按清单识别出存档，由清单校验报告缺少的文件
+		if (name == path.Base(dir) && isCandidateSaveFileName(name)) || name == manifestFileName {
+			saves = append(saves, archiveSave{Prefix: dir, Name: path.Base(dir)})
+		}
+	}
+
+	if len(saves) == 0 {
+		save, err := detectSingleZipSave(files, zipPath)
+		if err != nil {
+			return nil, err
+		}
+		return []archiveSave{save}, nil
+	}
+
+	// 按路径排序后，嵌套在其他存档目录中的目录视为该存档的一部分
+	sort.Slice(saves, func(i, j int) bool {
+		return saves[i].Prefix < saves[j].Prefix
+	})
+
+	var result []archiveSave
+	seen := make(map[string]string)
+	for _, save := range saves {
+		if len(result) > 0 && strings.HasPrefix(save.Prefix, result[len(result)-1].Prefix) {
+			continue
+		}
+
+		if !isValidSaveName(save.Name) {
+			return nil, fmt.Errorf("无效的存档名称: %s", save.Name)
+		}
+		if other, ok := seen[save.Name]; ok {
+			return nil, fmt.Errorf("压缩包中存在同名存档: %s 和 %s", other, save.Prefix)
+		}
+		seen[save.Name] = save.Prefix
+		result = append(result, save)
+	}
+
+	return result, nil
+}
+
+// detectSingleZipSave 按单个存档处理：使用第一个顶级目录，或者根目录中的文件
+func detectSingleZipSave(files []*zip.File, zipPath string) (archiveSave, error) {
+	var saveDir string
+	var rootFiles []string
+
+	for _, file := range files {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name := cleanArchiveName(file.Name)
+		dir := path.Dir(name)
+		if dir == "." {
+			rootFiles = append(rootFiles, name)
+		} else {
+			if saveDir == "" {
+				saveDir = strings.Split(dir, "/")[0]
+			}
+		}
+	}
+
+	// 确定目标目录名
+	var save archiveSave
+	if saveDir != "" {
+		save = archiveSave{Prefix: saveDir + "/", Name: saveDir}
+	} else {
+		if len(rootFiles) == 0 {
+			return save, fmt.Errorf("无效的存档文件结构")
+		}
+
+		// 文件在根目录时，主存档文件名就是游戏要求的目录名；找不到时才从压缩包文件名推断
+		for _, name := range rootFiles {
+			if isCandidateSaveFileName(name) {
+				save.Name = name
+				break
+			}
+		}
+		if save.Name == "" {
+			baseName := filepath.Base(zipPath)
+			save.Name = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+		}
+	}
+
+	if !isValidSaveName(save.Name) {
+		return save, fmt.Errorf("无效的存档名称: %s", save.Name)
+	}
+
+	return save, nil
+}
+
+// inspectZipSave 在解压前定位并解析压缩包中的主存档文件和 SaveGameInfo
+func (s *SaveService) inspectZipSave(files []*zip.File, save archiveSave) ImportPreview {
+	preview := ImportPreview{
+		Name: save.Name,
+		Save: SaveInfo{
+			ID:   save.Name,
+			Name: save.Name,
+		},
+	}
+
+	var mainFile, infoFile *zip.File
+	var candidates []*zip.File
+	for _, file := range files {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name := cleanArchiveName(file.Name)
+		if !strings.HasPrefix(name, save.Prefix) || isManifestEntry(name, save) {
+			continue
+		}
+		preview.Save.Size += int64(file.UncompressedSize64)
+
+		// 只在存档目录的第一层查找主文件
+		relPath := strings.TrimPrefix(name, save.Prefix)
+		if strings.Contains(relPath, "/") {
+			continue
+		}
+
+		switch {
+		case relPath == save.Name:
+			mainFile = file
+		case relPath == "SaveGameInfo":
+			infoFile = file
+		case isCandidateSaveFileName(relPath):
+			candidates = append(candidates, file)
+		}
+	}
+
+	if mainFile == nil && len(candidates) > 0 {
+		mainFile = candidates[0]
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("主存档文件名 %s 与目录名 %s 不一致，游戏可能无法识别", path.Base(mainFile.Name), save.Name))
+	}
+
+	if mainFile == nil {
+		preview.Errors = append(preview.Errors, "未找到主存档文件")
+		preview.Save.Error = "未找到有效的存档文件"
+		return preview
+	}
+
+	var gameData StardewSaveGame
+	if err := decodeZipXML(mainFile, &gameData); err != nil {
+		preview.Errors = append(preview.Errors, "解析主存档文件失败: "+err.Error())
+		preview.Save.Error = "解析存档文件失败: " + err.Error()
+		return preview
+	}
+	s.fillSaveInfo(&preview.Save, &gameData)
+	preview.Save.LastPlayed = mainFile.Modified
+
+	// SaveGameInfo 是游戏加载菜单显示存档所需的文件，缺失时只给出警告
+	if infoFile == nil {
+		preview.Warnings = append(preview.Warnings, "缺少 SaveGameInfo 文件，游戏的加载菜单中可能不显示该存档")
+	} else {
+		var farmer Player
+		if err := decodeZipXML(infoFile, &farmer); err != nil {
+			preview.Warnings = append(preview.Warnings, "解析 SaveGameInfo 失败: "+err.Error())
+		} else if farmer.Name != gameData.Player.Name {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("SaveGameInfo 中的玩家 %s 与主存档中的玩家 %s 不一致", farmer.Name, gameData.Player.Name))
+		}
+	}
+
+	preview.IsValid = true
+	return preview
+}
+
+// decodeZipXML 直接从压缩包条目解析XML
+func decodeZipXML(file *zip.File, v interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return xml.NewDecoder(reader).Decode(v)
+}
+
+// previewZipImport 校验压缩包并返回其中每个存档的导入预览，包括与现有存档的冲突信息
+func (s *SaveService) previewZipImport(rootPath, zipPath string) ([]ImportPreview, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	if err := validateZipEntries(reader.File); err != nil {
+		return nil, err
+	}
+
+	saves, err := detectZipSaves(reader.File, zipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make([]ImportPreview, 0, len(saves))
+	for _, save := range saves {
+		preview := s.inspectZipSave(reader.File, save)
+		checkZipManifest(reader.File, save, &preview)
+		preview.TargetPath = filepath.Join(rootPath, save.Name)
+		preview.Conflict = s.detectConflict(rootPath, preview)
+		previews = append(previews, preview)
+	}
+
+	return previews, nil
+}
+
+// detectConflict 目标位置已有同名存档时返回两者的差异
+func (s *SaveService) detectConflict(rootPath string, preview ImportPreview) *ConflictInfo {
+	if _, err := os.Stat(preview.TargetPath); err != nil {
+		return nil
+	}
+
+	existing := s.parseSaveDirectory(rootPath, preview.TargetPath)
+	return &ConflictInfo{
+		ExistingSave: existing,
+		NewSave:      preview.Save,
+		Differences:  compareSaveInfo(existing, preview.Save),
+	}
+}
+
+// compareSaveInfo 列出两个存档摘要中不同的字段
+func compareSaveInfo(existing, incoming SaveInfo) map[string]interface{} {
+	differences := make(map[string]interface{})
+
+	fields := []struct {
+		name          string
+		existing, new interface{}
+	}{
+		{"playerName", existing.PlayerName, incoming.PlayerName},
+		{"farmName", existing.FarmName, incoming.FarmName},
+		{"money", existing.Money, incoming.Money},
+		{"level", existing.Level, incoming.Level},
+		{"day", existing.Day, incoming.Day},
+		{"season", existing.Season, incoming.Season},
+		{"year", existing.Year, incoming.Year},
+		{"playTime", existing.PlayTime, incoming.PlayTime},
+	}
+	for _, field := range fields {
+		if field.existing != field.new {
+			differences[field.name] = gin.H{
+				"existing": field.existing,
+				"new":      field.new,
+			}
+		}
+	}
+
+	return differences
+}
+
+// extractAndImportSave 解压并导入压缩包中的所有存档，每个存档单独处理冲突，
+// 一个存档失败不影响其他存档
+func (s *SaveService) extractAndImportSave(ctx context.Context, rootPath, zipPath string, req ImportRequest, progress progressReporter) (*ImportResult, error) {
+	// 打开ZIP文件
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	// 解压前检查所有条目，拒绝路径穿越、符号链接和压缩炸弹
+	if err := validateZipEntries(reader.File); err != nil {
+		return nil, err
+	}
+
+	// 检查ZIP内容，找到所有存档目录
+	saves, err := detectZipSaves(reader.File, zipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.setTotal(countZipFiles(reader.File, saves))
+
+	result := &ImportResult{
+		Results: make([]ImportSaveResult, 0, len(saves)),
+	}
+	budget := newExtractBudget()
+	for _, save := range saves {
+		// 取消后不再开始导入新的存档，已经导入的存档保留
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		item := s.importZipSave(ctx, rootPath, reader.File, save, req, budget, progress)
+		if item.Status == ImportStatusImported {
+			result.Imported++
+		} else {
+			result.Failed++
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	return result, ctx.Err()
+}
+
+// countZipFiles 统计所有存档中需要解压的文件数量和大小
+func countZipFiles(files []*zip.File, saves []archiveSave) (int, int64) {
+	count := 0
+	var size int64
+	for _, file := range files {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name := cleanArchiveName(file.Name)
+		for _, save := range saves {
+			if strings.HasPrefix(name, save.Prefix) && !isManifestEntry(name, save) {
+				count++
+				size += int64(file.UncompressedSize64)
+				break
+			}
+		}
+	}
+	return count, size
+}
+
+// importZipSave 导入压缩包中的单个存档：先解压到暂存目录并校验，再原子替换到目标位置
+func (s *SaveService) importZipSave(ctx context.Context, rootPath string, files []*zip.File, save archiveSave, req ImportRequest, budget *extractBudget, progress progressReporter) ImportSaveResult {
+	targetPath := filepath.Join(rootPath, save.Name)
+	item := ImportSaveResult{
+		Name: save.Name,
+		Path: targetPath,
+	}
+	fail := func(status, format string, args ...interface{}) ImportSaveResult {
+		item.Status = status
+		item.Error = fmt.Sprintf(format, args...)
+		return item
+	}
+
+	// 在改动任何现有文件之前确认这是一个星露谷存档
+	preview := s.inspectZipSave(files, save)
+	preview.TargetPath = targetPath
+	item.Warnings = preview.Warnings
+
+	// 文件与导出清单不一致说明压缩包已损坏，即使允许导入无效存档也不导入
+	if err := checkZipManifest(files, save, &preview); err != nil {
+		item.Save = &preview.Save
+		return fail(ImportStatusFailed, "清单校验失败: %v", err)
+	}
+	item.Verified = preview.Verified
+
+	if !preview.IsValid {
+		if !req.AllowInvalid {
+			item.Save = &preview.Save
+			return fail(ImportStatusFailed, "不是有效的星露谷存档: %s", strings.Join(preview.Errors, "; "))
+		}
+		item.Warnings = append(item.Warnings, preview.Errors...)
+	}
+
+	// 同名存档只有在全局或针对该存档允许覆盖时才会被替换
+	overwrite := req.OverwriteExisting || containsString(req.OverwriteSaves, save.Name)
+	if !overwrite {
+		if conflict := s.detectConflict(rootPath, preview); conflict != nil {
+			item.Conflict = conflict
+			return fail(ImportStatusConflict, "存档已存在: %s", save.Name)
+		}
+	}
+
+	// 需要覆盖时先备份
+	exists, backupPath, err := s.checkSaveConflict(targetPath, overwrite, req.BackupExisting)
+	if err != nil {
+		return fail(ImportStatusFailed, "%v", err)
+	}
+	item.Overwrite = exists
+	item.BackupPath = backupPath
+
+	// 先解压到同一文件系统上的暂存目录，校验通过后再替换，原存档在此之前不会被改动
+	stagingPath := filepath.Join(rootPath, stagingPrefix+"import_"+uuid.New().String())
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return fail(ImportStatusFailed, "创建暂存目录失败: %v", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	for _, file := range files {
+		name := cleanArchiveName(file.Name)
+		if !strings.HasPrefix(name, save.Prefix) || isManifestEntry(name, save) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return fail(ImportStatusFailed, "导入已取消")
+		}
+		if err := s.extractFile(file, stagingPath, save.Prefix, budget); err != nil {
+			return fail(ImportStatusFailed, "解压文件失败: %v", err)
+		}
+		if !file.FileInfo().IsDir() {
+			progress.advance(1, int64(file.UncompressedSize64))
+		}
+	}
+
+	if preview.IsValid {
+		if err := s.validateStagedSave(stagingPath, save.Name); err != nil {
+			return fail(ImportStatusFailed, "解压后的存档校验失败: %v", err)
+		}
+	}
+
+	// 用重命名把暂存目录换到目标位置，失败时自动恢复原存档
+	if err := swapDirectory(stagingPath, targetPath); err != nil {
+		return fail(ImportStatusFailed, "替换存档失败: %v", err)
+	}
+
+	imported := s.parseSaveDirectory(rootPath, targetPath)
+	item.Status = ImportStatusImported
+	item.Save = &imported
+	return item
+}
+
+// extractFile 解压单个文件，prefix 为需要去掉的存档目录前缀
+func (s *SaveService) extractFile(file *zip.File, targetPath, prefix string, budget *extractBudget) error {
+	// 计算目标路径，去掉存档目录前缀
+	relativePath := strings.TrimPrefix(cleanArchiveName(file.Name), prefix)
+	if relativePath == "" {
+		return nil
+	}
+	filePath := filepath.Join(targetPath, filepath.FromSlash(relativePath))
+
+	// 再次确认解压位置没有逃逸出目标目录
+	if !isWithinDir(filepath.Clean(targetPath), filePath) {
+		return fmt.Errorf("非法的条目路径: %s", file.Name)
+	}
+
+	// 确保目录存在
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	// 跳过目录
+	if file.FileInfo().IsDir() {
+		return nil
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// 创建文件
+	writer, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	// 复制内容
+	return budget.copy(writer, reader)
+}
+
+// stagingPrefix 导入和转移过程中使用的隐藏暂存目录前缀，扫描存档时会被跳过
+const stagingPrefix = ".staging_"
+
+// replacedPrefix 替换存档时原目录暂时改成的名称前缀，后面是 uuid、下划线和原目录名
+const replacedPrefix = stagingPrefix + "replaced_"
+
+// validateStagedSave 确认暂存目录中的主存档文件可以正常解析
+func (s *SaveService) validateStagedSave(stagingPath, targetName string) error {
+	mainFile := filepath.Join(stagingPath, targetName)
+	if _, err := os.Stat(mainFile); err != nil {
+		mainFile = s.findMainSaveFile(stagingPath)
+	}
+	if mainFile == "" {
+		return fmt.Errorf("未找到主存档文件")
+	}
+
+	_, err := s.parseSaveFile(mainFile)
+	return err
+}
+
+// cleanupStagingDirs 清理异常退出后遗留的暂存目录
+func cleanupStagingDirs(rootPath string) {
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), stagingPrefix) {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), replacedPrefix) {
+			restoreReplacedDir(rootPath, entry.Name())
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < 24*time.Hour {
+			continue
+		}
+		os.RemoveAll(filepath.Join(rootPath, entry.Name()))
+	}
+}
+
+// restoreReplacedDir 处理替换存档时遗留的原目录。替换在两次重命名之间中断时目标目录已经不存在，
+// 原目录是存档唯一的副本，改回原名称；否则替换已经完成，直接删除
+func restoreReplacedDir(rootPath, name string) {
+	replacedPath := filepath.Join(rootPath, name)
+	rest := strings.TrimPrefix(name, replacedPrefix)
+	if len(rest) > 37 && rest[36] == '_' && isValidSaveName(rest[37:]) {
+		targetPath := filepath.Join(rootPath, rest[37:])
+		if _, err := os.Lstat(targetPath); os.IsNotExist(err) {
+			if err := os.Rename(replacedPath, targetPath); err != nil {
+				log.Printf("恢复替换中断的存档失败: %s: %v", rest[37:], err)
+			} else {
+				log.Printf("已恢复替换中断的存档: %s", rest[37:])
+			}
+			return
+		}
+	}
+	os.RemoveAll(replacedPath)
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
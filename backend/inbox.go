@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 收件箱中处理完成和处理失败的文件分别移动到这两个子目录
+const (
+	inboxProcessedDir = "processed"
+	inboxFailedDir    = "failed"
+)
+
+// inboxSettleTime 文件最后修改后需要等待的时间，避免导入仍在复制中的文件
+const inboxSettleTime = 10 * time.Second
+
+// inboxDir 返回导入收件箱目录：环境变量 SAVE_IMPORT_INBOX > 配置文件 importInbox > ./inbox
+func (s *SaveService) inboxDir() string {
+	if dir := os.Getenv("SAVE_IMPORT_INBOX"); dir != "" {
+		return filepath.Clean(dir)
+	}
+
+	s.mu.RLock()
+	dir := s.configInbox
+	s.mu.RUnlock()
+	if dir != "" {
+		return filepath.Clean(dir)
+	}
+	return "./inbox"
+}
+
+// inboxWatchInterval 返回自动导入的检查间隔：环境变量 SAVE_INBOX_WATCH_INTERVAL > 配置文件 inboxWatchInterval，
+// 单位为秒，0 表示不自动导入
+func (s *SaveService) inboxWatchInterval() int {
+	if value := os.Getenv("SAVE_INBOX_WATCH_INTERVAL"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return seconds
+		}
+		log.Printf("无效的 SAVE_INBOX_WATCH_INTERVAL: %s", value)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.configInboxInterval
+}
+
+// resolveInboxPath 把请求中的路径解析为收件箱中的绝对路径，路径不在收件箱内时返回错误
+func (s *SaveService) resolveInboxPath(path string) (string, error) {
+	inbox, err := resolvePath(s.inboxDir())
+	if err != nil {
+		return "", fmt.Errorf("收件箱目录不可用: %v", err)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(inbox, path)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", fmt.Errorf("路径不存在: %v", err)
+	}
+	if resolved == inbox || !isWithinDir(inbox, resolved) {
+		return "", fmt.Errorf("路径不在收件箱目录内")
+	}
+	return resolved, nil
+}
+
+// importLocalArchive 导入服务器上的压缩包或目录，先整理成ZIP再走与上传相同的导入流程
+func (s *SaveService) importLocalArchive(ctx context.Context, rootPath, srcPath string, req ImportRequest, progress progressReporter) (*ImportResult, error) {
+	tempDir, err := newTempDir("local_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	zipPath, err := s.convertToZip(srcPath, tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.extractAndImportSave(ctx, rootPath, zipPath, req, progress)
+}
+
+// GetInbox 列出收件箱中可以导入的压缩包和目录
+func (s *SaveService) GetInbox(c *gin.Context) {
+	inbox := s.inboxDir()
+	entries, err := os.ReadDir(inbox)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "读取收件箱失败: " + err.Error(),
+		})
+		return
+	}
+
+	result := InboxInfo{
+		Path:          inbox,
+		WatchInterval: s.inboxWatchInterval(),
+		Entries:       make([]InboxEntry, 0),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || name == inboxProcessedDir || name == inboxFailedDir {
+			continue
+		}
+		if !entry.IsDir() && uploadArchiveFormat(name) == "" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		item := InboxEntry{
+			Name:    name,
+			Path:    name,
+			IsDir:   entry.IsDir(),
+			ModTime: info.ModTime(),
+		}
+		if item.IsDir {
+			item.Size = s.calculateDirectorySize(filepath.Join(inbox, name))
+		} else {
+			item.Size = info.Size()
+		}
+		result.Entries = append(result.Entries, item)
+	}
+	sort.Slice(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Name < result.Entries[j].Name
+	})
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// ImportLocal 从收件箱中的压缩包或存档目录导入，不需要上传文件
+func (s *SaveService) ImportLocal(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	req := LocalImportRequest{}
+	req.BackupExisting = true
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	srcPath, err := s.resolveInboxPath(req.Path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if wantsAsync(c) {
+		s.submitJob(c, "import", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+			return s.runImportJob(srcPath, func() (*ImportResult, error) {
+				return s.importLocalArchive(ctx, lib.Path, srcPath, req.ImportRequest, job)
+			})
+		})
+		return
+	}
+
+	result, err := s.importLocalArchive(context.Background(), lib.Path, srcPath, req.ImportRequest, noProgress{})
+	if err != nil {
+		s.addLog("import", fmt.Sprintf("导入存档失败: %s", srcPath), false, err.Error())
+		s.respondConvertError(c, err)
+		return
+	}
+
+	s.logImportResult(result, srcPath)
+	s.respondImportResult(c, result)
+}
+
+// startInboxWatcher 配置了检查间隔时，定期把收件箱中的压缩包导入默认存档库
+func (s *SaveService) startInboxWatcher() {
+	interval := s.inboxWatchInterval()
+	if interval <= 0 {
+		return
+	}
+
+	log.Printf("自动导入已启用: %s，每 %d 秒检查一次", s.inboxDir(), interval)
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.processInbox()
+		}
+	}()
+}
+
+// processInbox 导入收件箱根目录中的压缩包，全部成功的移动到 processed，否则移动到 failed。
+// 自动导入不会覆盖同名存档，有冲突的压缩包会被移动到 failed
+func (s *SaveService) processInbox() {
+	inbox := s.inboxDir()
+	entries, err := os.ReadDir(inbox)
+	if err != nil {
+		log.Printf("读取收件箱失败: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || uploadArchiveFormat(name) == "" {
+			continue
+		}
+
+		// 跳过仍在写入的文件
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < inboxSettleTime {
+			continue
+		}
+
+		lib, ok := s.getLibrary("")
+		if !ok {
+			return
+		}
+
+		srcPath := filepath.Join(inbox, name)
+		result, err := s.importLocalArchive(context.Background(), lib.Path, srcPath, ImportRequest{BackupExisting: true}, noProgress{})
+
+		targetDir := inboxProcessedDir
+		switch {
+		case err != nil:
+			targetDir = inboxFailedDir
+			s.addLog("inbox", fmt.Sprintf("自动导入失败: %s", name), false, err.Error())
+		case result.Failed > 0:
+			targetDir = inboxFailedDir
+			s.logImportResult(result, srcPath)
+			s.addLog("inbox", fmt.Sprintf("自动导入: %s，%d 个成功，%d 个失败", name, result.Imported, result.Failed), false, "")
+		default:
+			s.logImportResult(result, srcPath)
+			s.addLog("inbox", fmt.Sprintf("自动导入: %s，%d 个存档", name, result.Imported), true, "")
+		}
+
+		if err := moveInboxFile(srcPath, filepath.Join(inbox, targetDir)); err != nil {
+			log.Printf("移动收件箱文件失败: %s: %v", name, err)
+		}
+	}
+}
+
+// moveInboxFile 将处理过的文件移动到子目录，目标已存在同名文件时加上时间戳
+func moveInboxFile(srcPath, targetDir string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	name := filepath.Base(srcPath)
+	targetPath := filepath.Join(targetDir, name)
+	if _, err := os.Stat(targetPath); err == nil {
+		targetPath = filepath.Join(targetDir, fmt.Sprintf("%d_%s", time.Now().Unix(), name))
+	}
+	return os.Rename(srcPath, targetPath)
+}
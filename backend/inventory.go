@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 箱子的种类
+const (
+	ContainerChest  = "chest"
+	ContainerFridge = "fridge"
+)
+
+// loadSaveDocument 读取并解析存档目录中的主存档文件
+func (s *SaveService) loadSaveDocument(savePath string) (*saveDocument, error) {
+	mainFile := s.findMainSaveFile(savePath)
+	if mainFile == "" {
+		return nil, fmt.Errorf("没有主存档文件")
+	}
+	data, err := readLimitedFile(mainFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseSaveDocument(data)
+}
+
+// inventoryItems 把存档中的物品转换成接口返回的格式，跳过空格子
+func inventoryItems(items []saveItem) []InventoryItem {
+	result := make([]InventoryItem, 0, len(items))
+	for _, item := range items {
+		if item.Nil {
+			continue
+		}
+		itemID := item.ItemID
+		if itemID == "" {
+			itemID = item.SheetID
+		}
+		result = append(result, InventoryItem{
+			Name:    item.displayName(),
+			Stack:   item.count(),
+			Quality: item.Quality,
+			Type:    item.Type,
+			ItemID:  itemID,
+		})
+	}
+	return result
+}
+
+// documentInventory 收集房主和农场帮手的背包，以及所有地点（包括建筑内部）中的箱子和冰箱
+func documentInventory(doc *saveDocument) ([]PlayerInventory, []ChestInventory) {
+	players := []PlayerInventory{{Player: doc.Player.Name, Host: true, Items: inventoryItems(doc.Player.Items)}}
+	chests := make([]ChestInventory, 0)
+
+	// 没有人加入过的农场帮手背包里也有游戏发放的初始工具，documentFarmhands 已经跳过了它们
+	for _, farmhand := range documentFarmhands(doc) {
+		players = append(players, PlayerInventory{Player: farmhand.Name, Items: inventoryItems(farmhand.Items)})
+	}
+
+	var walk func(locations []saveLocation, parent string)
+	walk = func(locations []saveLocation, parent string) {
+		for _, location := range locations {
+			name := location.Name
+			if name == "" {
+				name = location.Type
+			}
+			if parent != "" {
+				name = parent + "/" + name
+			}
+
+			if items := inventoryItems(location.Fridge); len(items) > 0 {
+				chests = append(chests, ChestInventory{Location: name, Kind: ContainerFridge, Items: items})
+			}
+			for _, object := range location.Objects {
+				if object.Value.Type != "Chest" {
+					continue
+				}
+				chests = append(chests, ChestInventory{
+					Location: name,
+					Kind:     ContainerChest,
+					X:        object.X,
+					Y:        object.Y,
+					Items:    inventoryItems(object.Value.Items),
+				})
+			}
+
+			for _, building := range location.Buildings {
+				if building.Indoors != nil {
+					walk([]saveLocation{*building.Indoors}, name)
+				}
+			}
+		}
+	}
+	walk(doc.Locations, "")
+
+	sort.SliceStable(chests, func(i, j int) bool {
+		a, b := chests[i], chests[j]
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+	return players, chests
+}
+
+// GetSaveInventory 获取存档中所有玩家的背包和所有箱子的内容
+func (s *SaveService) GetSaveInventory(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	save, err := s.getSaveByID(lib.Path, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "存档不存在",
+		})
+		return
+	}
+
+	doc, err := s.loadSaveDocument(save.Path)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, APIResponse{
+			Success: false,
+			Error:   "读取存档失败: " + err.Error(),
+		})
+		return
+	}
+
+	players, chests := documentInventory(doc)
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data: SaveInventory{
+			Save:    *save,
+			Players: players,
+			Chests:  chests,
+		},
+	})
+}
+
+// matchItem 物品名称包含关键词（不区分大小写），或物品编号与关键词相同
+func matchItem(item InventoryItem, query string) bool {
+	return strings.Contains(strings.ToLower(item.Name), query) || strings.EqualFold(item.ItemID, query)
+}
+
+// searchDocument 在一个存档的背包和箱子中搜索物品
+func searchDocument(doc *saveDocument, query string) []ItemMatch {
+	players, chests := documentInventory(doc)
+
+	matches := make([]ItemMatch, 0)
+	for _, player := range players {
+		for _, item := range player.Items {
+			if matchItem(item, query) {
+				matches = append(matches, ItemMatch{InventoryItem: item, Player: player.Player})
+			}
+		}
+	}
+	for _, chest := range chests {
+		for _, item := range chest.Items {
+			if matchItem(item, query) {
+				matches = append(matches, ItemMatch{InventoryItem: item, Location: chest.Location, Kind: chest.Kind, X: chest.X, Y: chest.Y})
+			}
+		}
+	}
+	return matches
+}
+
+// SearchItems 在所有存档的背包和箱子中搜索物品，q 为物品名称的一部分或物品编号，
+// 默认搜索所有存档库，可以用 library 参数指定一个存档库。无法解析的存档会被跳过
+func (s *SaveService) SearchItems(c *gin.Context) {
+	query := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "缺少搜索关键词",
+		})
+		return
+	}
+
+	libs := s.listLibraries()
+	if c.Query("library") != "" {
+		lib, ok := s.requestLibrary(c)
+		if !ok {
+			return
+		}
+		libs = []SaveLibrary{lib}
+	}
+
+	results := make([]ItemSearchResult, 0)
+	for _, lib := range libs {
+		saves, err := s.scanSaves(lib.Path)
+		if err != nil {
+			continue
+		}
+		for _, save := range saves {
+			if !save.IsValid {
+				continue
+			}
+			doc, err := s.loadSaveDocument(save.Path)
+			if err != nil {
+				continue
+			}
+			matches := searchDocument(doc, query)
+			if len(matches) == 0 {
+				continue
+			}
+
+			result := ItemSearchResult{Library: lib.Name, Save: save, Matches: matches}
+			for _, match := range matches {
+				result.Total += match.Stack
+			}
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Library != results[j].Library {
+			return results[i].Library < results[j].Library
+		}
+		return results[i].Save.Name < results[j].Save.Name
+	})
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    results,
+	})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 1.6 存档：没有人加入过的农场帮手没有名字，背包里有初始工具
+const inventory16Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <items>
+      <Item xsi:type="Tool"><name>Axe</name><itemId>(T)CopperAxe</itemId></Item>
+      <Item xsi:type="Object"><name>Parsnip</name><stack>12</stack><quality>2</quality><itemId>24</itemId></Item>
+      <Item xsi:nil="true" />
+    </items>
+  </player>
+  <farmhands>
+    <Farmer>
+      <name>Bob</name>
+      <items>
+        <Item xsi:type="Object"><name>Parsnip Seeds</name><stack>5</stack><itemId>472</itemId></Item>
+      </items>
+    </Farmer>
+    <Farmer>
+      <name></name>
+      <items>
+        <Item xsi:type="Tool"><name>Axe</name><itemId>(T)Axe</itemId></Item>
+        <Item xsi:type="Tool"><name>Hoe</name><itemId>(T)Hoe</itemId></Item>
+      </items>
+    </Farmer>
+  </farmhands>
+  <locations>
+    <GameLocation xsi:type="FarmHouse">
+      <name>FarmHouse</name>
+      <fridge><items>
+        <Item xsi:type="Object"><name>Milk</name><stack>3</stack><itemId>184</itemId></Item>
+      </items></fridge>
+    </GameLocation>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <objects>
+        <item>
+          <key><Vector2><X>5</X><Y>9</Y></Vector2></key>
+          <value><Object xsi:type="Chest"><name>Chest</name><items>
+            <Item xsi:type="Object"><name>Parsnip</name><stack>30</stack><itemId>24</itemId></Item>
+          </items></Object></value>
+        </item>
+        <item>
+          <key><Vector2><X>1</X><Y>1</Y></Vector2></key>
+          <value><Object xsi:type="Object"><name>Stone</name></Object></value>
+        </item>
+      </objects>
+      <buildings>
+        <Building>
+          <buildingType>Shed</buildingType>
+          <indoors xsi:type="Shed">
+            <name>Shed</name>
+            <objects>
+              <item>
+                <key><Vector2><X>2</X><Y>3</Y></Vector2></key>
+                <value><Object xsi:type="Chest"><name>Chest</name><items>
+                  <Item xsi:type="Object"><name>Wood</name><stack>99</stack><itemId>388</itemId></Item>
+                </items></Object></value>
+              </item>
+            </objects>
+          </indoors>
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+// 1.5 存档：农场帮手保存在小屋中，数量字段为 Stack
+const inventory15Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <items>
+      <Item xsi:type="Object"><name>Parsnip</name><Stack>4</Stack><parentSheetIndex>24</parentSheetIndex></Item>
+    </items>
+  </player>
+  <locations>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building>
+          <indoors xsi:type="Cabin">
+            <name>Cabin</name>
+            <farmhand>
+              <name>Carol</name>
+              <items>
+                <Item xsi:type="Axe"><name>Axe</name></Item>
+              </items>
+            </farmhand>
+          </indoors>
+        </Building>
+        <Building>
+          <indoors xsi:type="Cabin">
+            <name>Cabin</name>
+            <farmhand>
+              <name></name>
+              <items>
+                <Item xsi:type="Axe"><name>Axe</name></Item>
+                <Item xsi:type="Hoe"><name>Hoe</name></Item>
+                <Item xsi:type="WateringCan"><name>Watering Can</name></Item>
+                <Item xsi:type="Pickaxe"><name>Pickaxe</name></Item>
+                <Item xsi:type="MeleeWeapon"><name>Scythe</name></Item>
+              </items>
+            </farmhand>
+          </indoors>
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+func parseTestDocument(t *testing.T, data string) *saveDocument {
+	t.Helper()
+	doc, err := parseSaveDocument([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func inventoryPlayerNames(players []PlayerInventory) []string {
+	names := make([]string, 0, len(players))
+	for _, player := range players {
+		names = append(names, player.Player)
+	}
+	return names
+}
+
+func TestDocumentInventory16(t *testing.T) {
+	players, chests := documentInventory(parseTestDocument(t, inventory16Save))
+
+	if got, want := inventoryPlayerNames(players), []string{"Alice", "Bob"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("players = %v, want %v", got, want)
+	}
+	wantHost := PlayerInventory{Player: "Alice", Host: true, Items: []InventoryItem{
+		{Name: "Axe", Stack: 1, Type: "Tool", ItemID: "(T)CopperAxe"},
+		{Name: "Parsnip", Stack: 12, Quality: 2, Type: "Object", ItemID: "24"},
+	}}
+	if !reflect.DeepEqual(players[0], wantHost) {
+		t.Errorf("host = %+v, want %+v", players[0], wantHost)
+	}
+
+	wantChests := []ChestInventory{
+		{Location: "Farm", Kind: ContainerChest, X: 5, Y: 9, Items: []InventoryItem{{Name: "Parsnip", Stack: 30, Type: "Object", ItemID: "24"}}},
+		{Location: "Farm/Shed", Kind: ContainerChest, X: 2, Y: 3, Items: []InventoryItem{{Name: "Wood", Stack: 99, Type: "Object", ItemID: "388"}}},
+		{Location: "FarmHouse", Kind: ContainerFridge, Items: []InventoryItem{{Name: "Milk", Stack: 3, Type: "Object", ItemID: "184"}}},
+	}
+	if !reflect.DeepEqual(chests, wantChests) {
+		t.Errorf("chests = %+v, want %+v", chests, wantChests)
+	}
+}
+
+func TestDocumentInventory15(t *testing.T) {
+	players, chests := documentInventory(parseTestDocument(t, inventory15Save))
+
+	if got, want := inventoryPlayerNames(players), []string{"Alice", "Carol"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("players = %v, want %v", got, want)
+	}
+	wantHost := []InventoryItem{{Name: "Parsnip", Stack: 4, Type: "Object", ItemID: "24"}}
+	if !reflect.DeepEqual(players[0].Items, wantHost) {
+		t.Errorf("host items = %+v, want %+v", players[0].Items, wantHost)
+	}
+	if players[1].Host {
+		t.Error("farmhand marked as host")
+	}
+	if len(chests) != 0 {
+		t.Errorf("chests = %+v, want none", chests)
+	}
+}
+
+func TestSearchDocument(t *testing.T) {
+	doc := parseTestDocument(t, inventory16Save)
+
+	matches := searchDocument(doc, "parsnip")
+	want := []ItemMatch{
+		{InventoryItem: InventoryItem{Name: "Parsnip", Stack: 12, Quality: 2, Type: "Object", ItemID: "24"}, Player: "Alice"},
+		{InventoryItem: InventoryItem{Name: "Parsnip Seeds", Stack: 5, Type: "Object", ItemID: "472"}, Player: "Bob"},
+		{InventoryItem: InventoryItem{Name: "Parsnip", Stack: 30, Type: "Object", ItemID: "24"}, Location: "Farm", Kind: ContainerChest, X: 5, Y: 9},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("search parsnip = %+v, want %+v", matches, want)
+	}
+
+	// 按物品编号搜索
+	if matches := searchDocument(doc, "388"); len(matches) != 1 || matches[0].Location != "Farm/Shed" {
+		t.Errorf("search 388 = %+v, want the wood in the shed", matches)
+	}
+
+	// 空的农场帮手的初始工具不会被搜索到
+	for _, doc := range []*saveDocument{doc, parseTestDocument(t, inventory15Save)} {
+		for _, match := range searchDocument(doc, "hoe") {
+			t.Errorf("search hoe matched %+v", match)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 任务状态
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// 任务子系统的限制
+const (
+	jobQueueSize     = 100
+	jobRetention     = 24 * time.Hour // 完成的任务及其结果文件保留的时间
+	defaultJobWorker = 2
+)
+
+// jobsDir 任务结果文件的存放目录，每个任务一个子目录，只能通过任务接口下载
+const jobsDir = "./temp/jobs"
+
+// progressReporter 接收长时间操作的进度
+type progressReporter interface {
+	setTotal(files int, bytes int64)
+	advance(files int, bytes int64)
+}
+
+// noProgress 同步调用时不需要报告进度
+type noProgress struct{}
+
+func (noProgress) setTotal(int, int64) {}
+func (noProgress) advance(int, int64)  {}
+
+// jobFunc 任务的执行函数，返回值作为任务结果，ctx 在任务被取消时结束
+type jobFunc func(ctx context.Context, job *jobEntry) (interface{}, error)
+
+// jobEntry 任务及其运行时状态
+type jobEntry struct {
+	manager      *JobManager
+	job          Job
+	run          jobFunc
+	ctx          context.Context
+	cancel       context.CancelFunc
+	artifactPath string
+}
+
+// JobManager 管理后台任务：排队、由固定数量的工作协程执行，并保存进度和结果
+type JobManager struct {
+	mu    sync.Mutex
+	jobs  map[string]*jobEntry
+	queue chan *jobEntry
+}
+
+// jobWorkerCount 返回工作协程数量，可通过 SAVE_JOB_WORKERS 环境变量修改
+func jobWorkerCount() int {
+	if value := os.Getenv("SAVE_JOB_WORKERS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("无效的 SAVE_JOB_WORKERS: %s", value)
+	}
+	return defaultJobWorker
+}
+
+// cleanupJobArtifacts 删除上次运行遗留的任务结果文件。任务只保存在内存中，重启后这些文件已无法下载，
+// 只能在服务启动、还没有提交任何任务时调用
+func cleanupJobArtifacts() {
+	if err := os.RemoveAll(jobsDir); err != nil {
+		log.Printf("清理任务结果文件失败: %v", err)
+	}
+}
+
+// NewJobManager 创建任务管理器并启动工作协程
+func NewJobManager(workers int) *JobManager {
+	m := &JobManager{
+		jobs:  make(map[string]*jobEntry),
+		queue: make(chan *jobEntry, jobQueueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+	go m.janitor()
+	return m
+}
+
+// submit 提交任务，队列已满时返回错误
+func (m *JobManager) submit(owner, jobType string, run jobFunc) (Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := &jobEntry{
+		manager: m,
+		job: Job{
+			ID:        uuid.New().String(),
+			Type:      jobType,
+			Status:    JobStatusQueued,
+			Owner:     owner,
+			CreatedAt: time.Now(),
+		},
+		run:    run,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case m.queue <- entry:
+	default:
+		cancel()
+		return Job{}, fmt.Errorf("任务队列已满，请稍后再试")
+	}
+	m.jobs[entry.job.ID] = entry
+	return entry.job, nil
+}
+
+// worker 依次执行队列中的任务
+func (m *JobManager) worker() {
+	for entry := range m.queue {
+		m.mu.Lock()
+		if entry.job.Status != JobStatusQueued {
+			// 排队时已被取消
+			m.mu.Unlock()
+			continue
+		}
+		now := time.Now()
+		entry.job.Status = JobStatusRunning
+		entry.job.StartedAt = &now
+		m.mu.Unlock()
+
+		result, err := m.execute(entry)
+
+		m.mu.Lock()
+		finished := time.Now()
+		entry.job.FinishedAt = &finished
+		entry.job.Result = result
+		switch {
+		case err == nil:
+			entry.job.Status = JobStatusSucceeded
+		case errors.Is(err, context.Canceled):
+			entry.job.Status = JobStatusCancelled
+			entry.job.Error = "任务已取消"
+		default:
+			entry.job.Status = JobStatusFailed
+			entry.job.Error = err.Error()
+		}
+		// 失败或取消的任务不提供下载
+		if entry.job.Status != JobStatusSucceeded && entry.artifactPath != "" {
+			os.RemoveAll(filepath.Dir(entry.artifactPath))
+			entry.artifactPath = ""
+			entry.job.Artifact = ""
+		}
+		m.mu.Unlock()
+
+		entry.cancel()
+	}
+}
+
+// execute 执行任务，任务中的 panic 作为任务失败处理，不影响工作协程
+func (m *JobManager) execute(entry *jobEntry) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("任务 %s 异常: %v", entry.job.ID, r)
+			err = fmt.Errorf("任务异常: %v", r)
+		}
+	}()
+	return entry.run(entry.ctx, entry)
+}
+
+// get 获取任务的快照，只能获取自己提交的任务
+func (m *JobManager) get(id, owner string) (*jobEntry, Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.jobs[id]
+	if !ok || entry.job.Owner != owner {
+		return nil, Job{}, false
+	}
+	return entry, entry.job, true
+}
+
+// list 返回用户的任务，新任务在前
+func (m *JobManager) list(owner string) []Job {
+	m.mu.Lock()
+	jobs := make([]Job, 0)
+	for _, entry := range m.jobs {
+		if entry.job.Owner == owner {
+			jobs = append(jobs, entry.job)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// cancelJob 取消任务：排队中的任务直接标记为已取消，运行中的任务通过 ctx 通知停止
+func (m *JobManager) cancelJob(entry *jobEntry) Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch entry.job.Status {
+	case JobStatusQueued:
+		now := time.Now()
+		entry.job.Status = JobStatusCancelled
+		entry.job.Error = "任务已取消"
+		entry.job.FinishedAt = &now
+		entry.cancel()
+	case JobStatusRunning:
+		entry.job.Progress.Message = "正在取消"
+		entry.cancel()
+	}
+	return entry.job
+}
+
+// janitor 定期清理过期的任务及其结果文件
+func (m *JobManager) janitor() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mu.Lock()
+		for id, entry := range m.jobs {
+			if entry.job.FinishedAt != nil && time.Since(*entry.job.FinishedAt) > jobRetention {
+				if entry.artifactPath != "" {
+					os.RemoveAll(filepath.Dir(entry.artifactPath))
+				}
+				delete(m.jobs, id)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// setTotal 设置任务需要处理的文件数量和字节数
+func (e *jobEntry) setTotal(files int, bytes int64) {
+	e.manager.mu.Lock()
+	defer e.manager.mu.Unlock()
+	e.job.Progress.FilesTotal = files
+	e.job.Progress.BytesTotal = bytes
+}
+
+// advance 增加已处理的文件数量和字节数
+func (e *jobEntry) advance(files int, bytes int64) {
+	e.manager.mu.Lock()
+	defer e.manager.mu.Unlock()
+	e.job.Progress.FilesDone += files
+	e.job.Progress.BytesDone += bytes
+}
+
+// setMessage 更新任务当前的进度说明
+func (e *jobEntry) setMessage(message string) {
+	e.manager.mu.Lock()
+	defer e.manager.mu.Unlock()
+	e.job.Progress.Message = message
+}
+
+// createArtifact 在任务的私有目录中创建结果文件，任务成功后可以通过任务接口下载
+func (e *jobEntry) createArtifact(filename string) (*os.File, error) {
+	dir := filepath.Join(jobsDir, e.job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, filepath.Base(filename))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	e.manager.mu.Lock()
+	e.artifactPath = path
+	e.job.Artifact = filepath.Base(filename)
+	e.manager.mu.Unlock()
+	return file, nil
+}
+
+// wantsAsync 请求是否要求以后台任务方式执行
+func wantsAsync(c *gin.Context) bool {
+	return c.Query("async") == "true"
+}
+
+// submitJob 提交后台任务并立即返回任务信息，提交失败时写入错误响应并返回 false
+func (s *SaveService) submitJob(c *gin.Context, jobType string, run jobFunc) bool {
+	job, err := s.jobs.submit(requestUserID(c), jobType, run)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return false
+	}
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: "任务已提交",
+		Data:    job,
+	})
+	return true
+}
+
+// requestJob 获取当前用户的任务，失败时直接写入错误响应
+func (s *SaveService) requestJob(c *gin.Context) (*jobEntry, Job, bool) {
+	entry, job, ok := s.jobs.get(c.Param("id"), requestUserID(c))
+	if !ok {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "任务不存在或已过期",
+		})
+		return nil, Job{}, false
+	}
+	return entry, job, true
+}
+
+// GetJobs 列出当前用户的任务
+func (s *SaveService) GetJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.jobs.list(requestUserID(c)),
+	})
+}
+
+// GetJob 查询任务的状态、进度和结果
+func (s *SaveService) GetJob(c *gin.Context) {
+	_, job, ok := s.requestJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// CancelJob 取消排队中或运行中的任务
+func (s *SaveService) CancelJob(c *gin.Context) {
+	entry, job, ok := s.requestJob(c)
+	if !ok {
+		return
+	}
+
+	if job.Status != JobStatusQueued && job.Status != JobStatusRunning {
+		c.JSON(http.StatusConflict, APIResponse{
+			Success: false,
+			Error:   "任务已经结束",
+			Data:    job,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "已请求取消任务",
+		Data:    s.jobs.cancelJob(entry),
+	})
+}
+
+// DownloadJobArtifact 下载任务生成的文件
+func (s *SaveService) DownloadJobArtifact(c *gin.Context) {
+	entry, job, ok := s.requestJob(c)
+	if !ok {
+		return
+	}
+
+	s.jobs.mu.Lock()
+	artifactPath := entry.artifactPath
+	s.jobs.mu.Unlock()
+
+	if job.Status != JobStatusSucceeded || artifactPath == "" {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "任务没有可下载的文件",
+		})
+		return
+	}
+
+	c.FileAttachment(artifactPath, job.Artifact)
+}
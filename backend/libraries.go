@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLibraryName 启动时自动创建的存档库名称
+const defaultLibraryName = "default"
+
+// getLibrary 按名称获取存档库，名称为空时返回默认库
+func (s *SaveService) getLibrary(name string) (SaveLibrary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name == "" {
+		name = s.defaultLibrary
+	}
+
+	lib, ok := s.libraries[name]
+	if !ok {
+		return SaveLibrary{}, false
+	}
+	lib.IsDefault = lib.Name == s.defaultLibrary
+	return lib, true
+}
+
+// requestLibrary 根据请求的 library 参数解析存档库，失败时直接写入错误响应
+func (s *SaveService) requestLibrary(c *gin.Context) (SaveLibrary, bool) {
+	name := c.Query("library")
+	lib, ok := s.getLibrary(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("存档库不存在: %s", name),
+		})
+		return SaveLibrary{}, false
+	}
+	return lib, true
+}
+
+// listLibraries 返回按名称排序的存档库列表
+func (s *SaveService) listLibraries() []SaveLibrary {
+	s.mu.RLock()
+	libs := make([]SaveLibrary, 0, len(s.libraries))
+	for _, lib := range s.libraries {
+		lib.IsDefault = lib.Name == s.defaultLibrary
+		libs = append(libs, lib)
+	}
+	s.mu.RUnlock()
+
+	for i := range libs {
+		libs[i].IsValid = s.isValidPath(libs[i].Path)
+	}
+
+	sort.Slice(libs, func(i, j int) bool {
+		return libs[i].Name < libs[j].Name
+	})
+	return libs
+}
+
+// validateLibraries 启动时检查每个存档库是否存在且位于允许的根目录内。默认存档库不可用时改用第一个可用的允许根目录，
+// 其他不可用的存档库只记录日志，避免 SAVE_ALLOWED_ROOTS 排除了保存的路径时服务静默地以不可用的存档库启动。
+// 调用方不能持有 s.mu
+func (s *SaveService) validateLibraries() {
+	for _, lib := range s.listLibraries() {
+		if lib.IsValid {
+			continue
+		}
+		if !lib.IsDefault {
+			log.Printf("存档库 %s 的路径不存在或不在允许的根目录内，该存档库不可用: %s", lib.Name, lib.Path)
+			continue
+		}
+
+		fallback := ""
+		for _, root := range s.allowedRoots() {
+			if s.isValidPath(root) {
+				fallback = root
+				break
+			}
+		}
+		if fallback == "" {
+			log.Printf("默认存档库 %s 的路径不存在或不在允许的根目录内，且没有可用的允许根目录: %s，允许的根目录: %v",
+				lib.Name, lib.Path, s.allowedRoots())
+			continue
+		}
+		log.Printf("默认存档库 %s 的路径不存在或不在允许的根目录内: %s，改用 %s", lib.Name, lib.Path, fallback)
+		s.setLibraryPath(lib.Name, fallback)
+	}
+}
+
+// setLibraryPath 更新存档库的根目录并记录到最近路径
+func (s *SaveService) setLibraryPath(name, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lib := s.libraries[name]
+	lib.Name = name
+	lib.Path = path
+	s.libraries[name] = lib
+	s.addToRecentPaths(path)
+}
+
+// GetLibraries 获取存档库列表
+func (s *SaveService) GetLibraries(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.listLibraries(),
+	})
+}
+
+// SetLibrary 创建或更新存档库
+func (s *SaveService) SetLibrary(c *gin.Context) {
+	var req LibraryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	if !isValidSaveName(req.Name) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "存档库名称无效",
+		})
+		return
+	}
+
+	path := filepath.Clean(req.Path)
+	if !s.isValidPath(path) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "路径无效或不安全",
+		})
+		return
+	}
+
+	s.mu.Lock()
+	_, existed := s.libraries[req.Name]
+	s.libraries[req.Name] = SaveLibrary{
+		Name:        req.Name,
+		Path:        path,
+		Description: req.Description,
+	}
+	if req.SetDefault {
+		s.defaultLibrary = req.Name
+	}
+	s.addToRecentPaths(path)
+	s.mu.Unlock()
+	s.persistConfig()
+
+	if existed {
+		s.addLog("library_update", fmt.Sprintf("更新存档库 %s: %s", req.Name, path), true, "")
+	} else {
+		s.addLog("library_create", fmt.Sprintf("创建存档库 %s: %s", req.Name, path), true, "")
+	}
+
+	lib, _ := s.getLibrary(req.Name)
+	lib.IsValid = true
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "存档库保存成功",
+		Data:    lib,
+	})
+}
+
+// DeleteLibrary 删除存档库（仅移除配置，不删除目录内容）
+func (s *SaveService) DeleteLibrary(c *gin.Context) {
+	name := c.Param("name")
+
+	s.mu.Lock()
+	if _, ok := s.libraries[name]; !ok {
+		s.mu.Unlock()
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("存档库不存在: %s", name),
+		})
+		return
+	}
+	if name == s.defaultLibrary {
+		s.mu.Unlock()
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "不能删除默认存档库",
+		})
+		return
+	}
+	delete(s.libraries, name)
+	s.mu.Unlock()
+	s.persistConfig()
+
+	s.addLog("library_delete", fmt.Sprintf("删除存档库: %s", name), true, "")
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "存档库删除成功",
+	})
+}
+
+// SetDefaultLibrary 设置默认存档库
+func (s *SaveService) SetDefaultLibrary(c *gin.Context) {
+	name := c.Param("name")
+
+	s.mu.Lock()
+	if _, ok := s.libraries[name]; !ok {
+		s.mu.Unlock()
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("存档库不存在: %s", name),
+		})
+		return
+	}
+	s.defaultLibrary = name
+	s.mu.Unlock()
+	s.persistConfig()
+
+	s.addLog("library_default", fmt.Sprintf("设置默认存档库: %s", name), true, "")
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "默认存档库设置成功",
+		Data:    s.listLibraries(),
+	})
+}
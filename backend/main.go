@@ -17,7 +17,9 @@ func main() {
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
 
-	r := gin.Default()
+	// 流式下载出错时需要中断连接，中断中间件在 Logger 和 Recovery 之内，连接由 abortStreamHandler 中断
+	r := gin.New()
+	r.Use(gin.Logger(), gin.Recovery(), abortStreamMiddleware())
 
 	// CORS配置
 	config := cors.DefaultConfig()
@@ -26,9 +28,6 @@ func main() {
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
 	r.Use(cors.New(config))
 
-	// 静态文件服务 - 用于下载存档
-	r.Static("/downloads", "./downloads")
-
 	// 初始化服务
 	saveService := NewSaveService()
 	authService := NewAuthService()
@@ -38,6 +37,12 @@ func main() {
 		log.Printf("加载用户数据失败: %v", err)
 	}
 
+	// 清理上次遗留的任务结果，启动收件箱自动导入、过期上传的清理，继续上次没有完成的备份复制
+	cleanupJobArtifacts()
+	saveService.startInboxWatcher()
+	saveService.startUploadJanitor()
+	saveService.resumeReplication()
+
 	// API路由组
 	api := r.Group("/api")
 	{
@@ -58,13 +63,31 @@ func main() {
 			protected.POST("/set-path", saveService.SetPath)
 			protected.GET("/validate-path", saveService.ValidatePath)
 			protected.GET("/path-info", saveService.GetPathInfo)
+			protected.GET("/browse", saveService.BrowseDirectories)
+
+			// 存档库管理
+			protected.GET("/libraries", saveService.GetLibraries)
+			protected.POST("/libraries", saveService.SetLibrary)
+			protected.DELETE("/libraries/:name", saveService.DeleteLibrary)
+			protected.POST("/libraries/:name/default", saveService.SetDefaultLibrary)
 
 			// 存档管理
 			protected.GET("/saves", saveService.GetSaves)
 			protected.GET("/saves/:id", saveService.GetSaveDetails)
 			protected.DELETE("/saves/:id", saveService.DeleteSave)
+			protected.POST("/saves/:id/rename", saveService.RenameSave)
+			protected.POST("/saves/:id/transfer", saveService.TransferSave)
 			protected.POST("/saves/import", saveService.ImportSave)
+			protected.POST("/saves/import/preview", saveService.PreviewImport)
+			protected.POST("/saves/import/local", saveService.ImportLocal)
 			protected.GET("/saves/:id/export", saveService.ExportSave)
+			protected.GET("/saves/:id/history", saveService.GetSaveHistory)
+			protected.POST("/saves/:id/history", saveService.CreateSnapshot)
+			protected.GET("/saves/:id/history/diff", saveService.GetHistoryDiff)
+			protected.POST("/saves/:id/history/restore", saveService.RestoreFromHistory)
+			protected.GET("/history", saveService.GetHistorySaves)
+			protected.GET("/saves/:id/inventory", saveService.GetSaveInventory)
+			protected.GET("/items/search", saveService.SearchItems)
 			protected.POST("/saves/batch-export", saveService.BatchExport)
 			protected.DELETE("/saves/batch-delete", saveService.BatchDelete)
 
@@ -72,10 +95,36 @@ func main() {
 			protected.POST("/saves/compare", saveService.CompareSaves)
 			protected.POST("/saves/resolve-conflict", saveService.ResolveConflict)
 
+			// 分块上传
+			protected.POST("/uploads", saveService.InitUpload)
+			protected.GET("/uploads/:id", saveService.GetUpload)
+			protected.PUT("/uploads/:id", saveService.UploadChunk)
+			protected.POST("/uploads/:id/complete", saveService.CompleteUpload)
+			protected.DELETE("/uploads/:id", saveService.CancelUpload)
+
+			// 导入收件箱
+			protected.GET("/inbox", saveService.GetInbox)
+
+			// 后台任务
+			protected.GET("/jobs", saveService.GetJobs)
+			protected.GET("/jobs/:id", saveService.GetJob)
+			protected.POST("/jobs/:id/cancel", saveService.CancelJob)
+			protected.GET("/jobs/:id/download", saveService.DownloadJobArtifact)
+
+			// 存档库备份
+			protected.GET("/backups", saveService.ListBackups)
+			protected.POST("/backups", saveService.CreateLibraryBackup)
+			protected.POST("/backups/:name/restore", saveService.RestoreBackup)
+			protected.POST("/backups/:name/replicate", saveService.ReplicateBackup)
+			protected.GET("/backup-targets", saveService.GetBackupTargets)
+
 			// 操作日志
 			protected.GET("/logs", saveService.GetLogs)
 		}
 
+		// 签名下载链接（由链接中的签名授权，无需登录）
+		api.GET("/downloads/export", saveService.DownloadExport)
+
 		// 健康检查（无需认证）
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"status": "ok"})
@@ -85,7 +134,7 @@ func main() {
 	// 创建HTTP服务器
 	srv := &http.Server{
 		Addr:    ":8080",
-		Handler: r,
+		Handler: abortStreamHandler(r),
 	}
 
 	// 在新的goroutine中启动服务器
@@ -109,6 +158,11 @@ func main() {
 		log.Println("用户数据已保存")
 	}
 
+	// 保存路径配置
+	if err := saveService.saveConfig(); err != nil {
+		log.Printf("保存路径配置失败: %v", err)
+	}
+
 	// 给一些时间来完成现有的请求
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
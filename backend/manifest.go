@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestFileName 导出清单在存档目录中的文件名，导入时不会解压到存档目录
+const manifestFileName = "stardew-manifest.json"
+
+// manifestVersion 当前的清单格式版本
+const manifestVersion = 1
+
+// isManifestEntry 判断压缩包条目是否是存档的导出清单
+func isManifestEntry(name string, save archiveSave) bool {
+	return name == save.Prefix+manifestFileName
+}
+
+// writeSaveToZip 写入存档目录，并在目录末尾附加记录了每个文件 SHA-256 的导出清单
+func (s *SaveService) writeSaveToZip(ctx context.Context, zipWriter *zip.Writer, opts zipOptions, save SaveInfo, dirName, exportedBy string, progress progressReporter) error {
+	files, err := s.writeDirectoryToZip(ctx, zipWriter, opts, save.Path, dirName, progress)
+	if err != nil {
+		return err
+	}
+
+	manifest := s.buildManifest(save, exportedBy, files)
+	if opts.deterministic {
+		// 确定性模式下清单中只保留由存档内容决定的信息
+		manifest.ExportedAt = nil
+		manifest.ExportedBy = ""
+		manifest.Save.LastPlayed = time.Time{}
+	}
+	return writeZipJSON(zipWriter, opts, path.Join(dirName, manifestFileName), manifest)
+}
+
+// buildManifest 生成导出清单，清单中不包含存档在服务器上的路径
+func (s *SaveService) buildManifest(save SaveInfo, exportedBy string, files []ManifestFile) ExportManifest {
+	now := time.Now()
+	manifest := ExportManifest{
+		Version:    manifestVersion,
+		SaveID:     save.ID,
+		Save:       save,
+		ExportedAt: &now,
+		ExportedBy: exportedBy,
+		Files:      files,
+	}
+	manifest.Save.Path = ""
+
+	if mainFile := s.findMainSaveFile(save.Path); mainFile != "" {
+		if gameData, err := s.parseSaveFile(mainFile); err == nil {
+			manifest.UniqueID = gameData.UniqueID
+		}
+	}
+	return manifest
+}
+
+// checkZipManifest 存档附带导出清单时，校验压缩包中的文件与清单一致，结果记录在预览中。
+// 没有清单的存档（例如旧版本导出或手工打包的）不做校验
+func checkZipManifest(files []*zip.File, save archiveSave, preview *ImportPreview) error {
+	manifest, err := verifyZipManifest(files, save)
+	if manifest != nil {
+		preview.Manifest = manifest
+		// 清单中的文件列表只用于校验，不返回给客户端
+		preview.Manifest.Files = nil
+	}
+	if err != nil {
+		preview.IsValid = false
+		preview.Errors = append(preview.Errors, "清单校验失败: "+err.Error())
+		return err
+	}
+
+	preview.Verified = manifest != nil
+	return nil
+}
+
+// verifyZipManifest 读取存档的导出清单并逐个比较文件的大小和 SHA-256，没有清单时返回 nil
+func verifyZipManifest(files []*zip.File, save archiveSave) (*ExportManifest, error) {
+	var manifestFile *zip.File
+	entries := make(map[string]*zip.File)
+	for _, file := range files {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name := cleanArchiveName(file.Name)
+		if !strings.HasPrefix(name, save.Prefix) {
+			continue
+		}
+		if isManifestEntry(name, save) {
+			manifestFile = file
+			continue
+		}
+		entries[strings.TrimPrefix(name, save.Prefix)] = file
+	}
+
+	if manifestFile == nil {
+		return nil, nil
+	}
+
+	reader, err := manifestFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %v", err)
+	}
+	var manifest ExportManifest
+	err = json.NewDecoder(reader).Decode(&manifest)
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("解析清单失败: %v", err)
+	}
+	if manifest.Version > manifestVersion {
+		return &manifest, fmt.Errorf("不支持的清单版本: %d", manifest.Version)
+	}
+
+	for _, item := range manifest.Files {
+		file, ok := entries[item.Path]
+		if !ok {
+			return &manifest, fmt.Errorf("缺少文件: %s", item.Path)
+		}
+		delete(entries, item.Path)
+
+		if int64(file.UncompressedSize64) != item.Size {
+			return &manifest, fmt.Errorf("文件大小不一致: %s", item.Path)
+		}
+		sum, err := zipEntrySHA256(file)
+		if err != nil {
+			return &manifest, fmt.Errorf("读取文件失败: %s: %v", item.Path, err)
+		}
+		if sum != strings.ToLower(item.SHA256) {
+			return &manifest, fmt.Errorf("文件内容与清单不一致: %s", item.Path)
+		}
+	}
+
+	// 清单之外多出来的文件同样说明压缩包在导出后被改动过
+	if len(entries) > 0 {
+		extra := make([]string, 0, len(entries))
+		for name := range entries {
+			extra = append(extra, name)
+		}
+		sort.Strings(extra)
+		return &manifest, fmt.Errorf("包含清单中没有的文件: %s", strings.Join(extra, ", "))
+	}
+
+	return &manifest, nil
+}
+
+// zipEntrySHA256 计算压缩包条目解压后内容的 SHA-256
+func zipEntrySHA256(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -21,16 +21,115 @@ type SaveInfo struct {
 	Path       string    `json:"path"`
 	IsValid    bool      `json:"isValid"`
 	Error      string    `json:"error,omitempty"`
+
+	Relationships *SaveRelationships `json:"relationships,omitempty"` // 只在存档详情中返回
 }
 
 // PathConfig 路径配置
 type PathConfig struct {
+	Library     string   `json:"library"`
 	CurrentPath string   `json:"currentPath"`
 	RecentPaths []string `json:"recentPaths"`
 	IsValid     bool     `json:"isValid"`
 	Error       string   `json:"error,omitempty"`
 }
 
+// BrowseEntry 目录浏览中的子目录
+type BrowseEntry struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	IsSaveFolder bool   `json:"isSaveFolder"` // 包含可解析的存档，可以作为存档路径
+	IsSave       bool   `json:"isSave"`       // 本身就是单个存档目录
+}
+
+// BrowseResult 目录浏览结果
+type BrowseResult struct {
+	Path         string        `json:"path"`
+	Parent       string        `json:"parent,omitempty"`
+	IsRoot       bool          `json:"isRoot"`
+	IsSaveFolder bool          `json:"isSaveFolder"`
+	SaveCount    int           `json:"saveCount"`
+	Entries      []BrowseEntry `json:"entries"`
+}
+
+// SaveLibrary 存档库，每个库对应一个独立的存档根目录
+type SaveLibrary struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	IsDefault   bool   `json:"isDefault"`
+	IsValid     bool   `json:"isValid"`
+}
+
+// LibraryConfig 配置文件中保存的存档库
+type LibraryConfig struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+}
+
+// ServiceConfig 持久化到配置文件的存档服务配置
+type ServiceConfig struct {
+	Libraries      []LibraryConfig `json:"libraries"`
+	DefaultLibrary string          `json:"defaultLibrary"`
+	RecentPaths    []string        `json:"recentPaths"`
+	PathCandidates []string        `json:"pathCandidates,omitempty"`
+	AllowedRoots   []string        `json:"allowedRoots,omitempty"`
+
+	// 导入收件箱目录，以及自动导入的检查间隔（秒，0 表示不自动导入）
+	ImportInbox        string `json:"importInbox,omitempty"`
+	InboxWatchInterval int    `json:"inboxWatchInterval,omitempty"`
+
+	// 导出和备份的压缩级别（store、fast、default、best），以及是否生成确定性的压缩包
+	ZipCompression   string `json:"zipCompression,omitempty"`
+	ZipDeterministic bool   `json:"zipDeterministic,omitempty"`
+
+	// 新备份需要复制到的异地存储
+	BackupTargets []BackupTargetConfig `json:"backupTargets,omitempty"`
+
+	// 删除或覆盖存档前是否自动把存档提交到 git 历史
+	HistoryEnabled bool `json:"historyEnabled,omitempty"`
+}
+
+// BackupTargetConfig 备份复制目标。type 为 local、s3 或 sftp，
+// accessKey、secretKey 和 password 中可以用 ${VAR} 引用环境变量
+type BackupTargetConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Path   string `json:"path,omitempty"`   // local 和 sftp 的目录
+	Prefix string `json:"prefix,omitempty"` // s3 的对象键前缀
+
+	// S3 兼容对象存储，默认使用路径风格的地址（MinIO 等）
+	Endpoint    string `json:"endpoint,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Bucket      string `json:"bucket,omitempty"`
+	AccessKey   string `json:"accessKey,omitempty"`
+	SecretKey   string `json:"secretKey,omitempty"`
+	VirtualHost bool   `json:"virtualHost,omitempty"`
+
+	// SFTP，hostKey 为 authorized_keys 格式的服务器公钥，也可以用 knownHostsFile 校验
+	Host                  string `json:"host,omitempty"`
+	Port                  int    `json:"port,omitempty"`
+	User                  string `json:"user,omitempty"`
+	Password              string `json:"password,omitempty"`
+	PrivateKeyFile        string `json:"privateKeyFile,omitempty"`
+	HostKey               string `json:"hostKey,omitempty"`
+	KnownHostsFile        string `json:"knownHostsFile,omitempty"`
+	InsecureIgnoreHostKey bool   `json:"insecureIgnoreHostKey,omitempty"`
+}
+
+// PathCandidateStatus 候选存档路径的检测结果
+type PathCandidateStatus struct {
+	Path      string `json:"path"`
+	Priority  int    `json:"priority"`
+	Source    string `json:"source"` // env, config, builtin, system
+	Exists    bool   `json:"exists"`
+	IsDir     bool   `json:"isDir"`
+	SaveCount int    `json:"saveCount"`
+	Current   bool   `json:"current"`
+	Error     string `json:"error,omitempty"`
+}
+
 // ConflictInfo 冲突信息
 type ConflictInfo struct {
 	ExistingSave SaveInfo               `json:"existingSave"`
@@ -51,18 +150,480 @@ type OperationLog struct {
 // BatchRequest 批量操作请求
 type BatchRequest struct {
 	SaveIDs []string `json:"saveIds"`
+	Force   bool     `json:"force"` // 批量删除时，备份失败也继续删除
 }
 
 // ImportRequest 导入请求
 type ImportRequest struct {
+	OverwriteExisting bool     `json:"overwriteExisting"`
+	BackupExisting    bool     `json:"backupExisting"`
+	AllowInvalid      bool     `json:"allowInvalid"`             // 不是有效存档时仍然导入，仅给出警告
+	OverwriteSaves    []string `json:"overwriteSaves,omitempty"` // 只允许覆盖这些同名存档
+	SaveName          string   `json:"saveName,omitempty"`
+}
+
+// LocalImportRequest 从服务器收件箱目录导入的请求，Path 可以是相对收件箱的路径
+type LocalImportRequest struct {
+	Path string `json:"path" binding:"required"`
+	ImportRequest
+}
+
+// UploadInitRequest 创建分块上传会话的请求
+type UploadInitRequest struct {
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+	SHA256   string `json:"sha256" binding:"required"` // 完成上传时校验整个文件
+}
+
+// UploadSession 分块上传会话
+type UploadSession struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"` // 已上传的字节数，下一个分块从这里开始
+	SHA256    string    `json:"sha256,omitempty"`
+	ChunkSize int64     `json:"chunkSize"` // 建议的分块大小
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	writing bool // 正在写入分块或正在导入
+}
+
+// JobProgress 后台任务的进度
+type JobProgress struct {
+	FilesTotal int    `json:"filesTotal"`
+	FilesDone  int    `json:"filesDone"`
+	BytesTotal int64  `json:"bytesTotal"`
+	BytesDone  int64  `json:"bytesDone"`
+	Message    string `json:"message,omitempty"`
+}
+
+// Job 后台任务
+type Job struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`   // import, batch_export, batch_delete, library_backup
+	Status     string      `json:"status"` // queued, running, succeeded, failed, cancelled
+	Owner      string      `json:"owner"`
+	Progress   JobProgress `json:"progress"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Artifact   string      `json:"artifact,omitempty"` // 可通过 /api/jobs/:id/download 下载的文件名
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+}
+
+// BackupInfo 备份目录或复制目标中的备份文件
+type BackupInfo struct {
+	Name      string          `json:"name"`
+	Size      int64           `json:"size"`
+	Encrypted bool            `json:"encrypted"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Replicas  []BackupReplica `json:"replicas,omitempty"`
+}
+
+// 备份复制状态
+const (
+	ReplicaStatusPending    = "pending"
+	ReplicaStatusReplicated = "replicated"
+	ReplicaStatusFailed     = "failed"
+)
+
+// BackupReplica 备份在一个复制目标上的状态
+type BackupReplica struct {
+	Target    string    `json:"target"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// HistoryEntry 存档历史中的一次快照
+type HistoryEntry struct {
+	Commit    string    `json:"commit"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"createdAt"`
+	Message   string    `json:"message"`
+	Reason    string    `json:"reason,omitempty"`
+	GameDate  string    `json:"gameDate,omitempty"`
+	Money     int64     `json:"money"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+// HistorySave 有历史记录的存档，存档目录可能已被删除
+type HistorySave struct {
+	Name      string    `json:"name"`
+	Head      string    `json:"head"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Exists    bool      `json:"exists"`
+}
+
+// HistoryDiffFile 两次快照之间变化的文件
+type HistoryDiffFile struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// HistoryDiff 两次快照之间的差异
+type HistoryDiff struct {
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Files     []HistoryDiffFile `json:"files"`
+	Patch     string            `json:"patch"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Changes   *SaveDiff         `json:"changes,omitempty"` // 主存档文件的内容差异
+}
+
+// SaveVersionRef 参与比较的一个存档版本。source 为 live（存档库中的存档）、backup（备份文件）、
+// history（历史快照）或 upload（随请求上传的文件，字段名为 baseFile 或 targetFile）。
+// 备份或上传的压缩包中有多个存档时用 id 选择存档目录
+type SaveVersionRef struct {
+	Source  string `json:"source"`
+	Library string `json:"library,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Backup  string `json:"backup,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// CompareRequest 比较两个存档版本，target 相对于 base 的变化
+type CompareRequest struct {
+	Base   SaveVersionRef `json:"base"`
+	Target SaveVersionRef `json:"target"`
+}
+
+// 存档差异中的变化类型
+const (
+	ChangeAdded     = "added"
+	ChangeRemoved   = "removed"
+	ChangeChanged   = "changed"
+	ChangeUpgraded  = "upgraded"
+	ChangeCompleted = "completed"
+)
+
+// SaveDiff 两个存档版本之间按游戏内容整理的差异
+type SaveDiff struct {
+	Base        SaveVersionInfo    `json:"base"`
+	Target      SaveVersionInfo    `json:"target"`
+	Identical   bool               `json:"identical"`
+	Summary     []string           `json:"summary"`
+	Fields      []SaveFieldChange  `json:"fields"`
+	Inventory   []InventoryChange  `json:"inventory"`
+	Friendships []FriendshipChange `json:"friendships"`
+	Buildings   []BuildingChange   `json:"buildings"`
+	Quests      []QuestChange      `json:"quests"`
+	Farmhands   []FarmhandDiff     `json:"farmhands"`
+}
+
+// FarmhandDiff 一个农场帮手的变化，按 UniqueMultiplayerID 对应。
+// 新加入或不再出现的农场帮手只有 change，没有具体的变化
+type FarmhandDiff struct {
+	Player      string             `json:"player"`
+	UniqueID    int64              `json:"uniqueId,string"`
+	Change      string             `json:"change"`
+	Fields      []SaveFieldChange  `json:"fields"`
+	Inventory   []InventoryChange  `json:"inventory"`
+	Friendships []FriendshipChange `json:"friendships"`
+	Quests      []QuestChange      `json:"quests"`
+}
+
+// SaveVersionInfo 比较的存档版本
+type SaveVersionInfo struct {
+	Label string   `json:"label"`
+	Save  SaveInfo `json:"save"`
+}
+
+// SaveFieldChange 玩家或存档的一个数值变化
+type SaveFieldChange struct {
+	Field string      `json:"field"`
+	Label string      `json:"label"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+	Delta int64       `json:"delta,omitempty"`
+}
+
+// InventoryChange 背包中一种物品（同名同品质）数量的变化
+type InventoryChange struct {
+	Name    string `json:"name"`
+	Quality int    `json:"quality"`
+	Old     int    `json:"old"`
+	New     int    `json:"new"`
+	Delta   int    `json:"delta"`
+	Change  string `json:"change"`
+}
+
+// FriendshipChange 与一个角色的关系变化，每 250 点好感为一颗心
+type FriendshipChange struct {
+	Name      string `json:"name"`
+	OldPoints int    `json:"oldPoints"`
+	NewPoints int    `json:"newPoints"`
+	OldHearts int    `json:"oldHearts"`
+	NewHearts int    `json:"newHearts"`
+	OldStatus string `json:"oldStatus,omitempty"`
+	NewStatus string `json:"newStatus,omitempty"`
+	Change    string `json:"change"`
+}
+
+// BuildingChange 建筑的变化，按所在地点和坐标对应
+type BuildingChange struct {
+	Type     string `json:"type"`
+	OldType  string `json:"oldType,omitempty"`
+	Location string `json:"location"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Change   string `json:"change"`
+}
+
+// QuestChange 任务日志的变化，从日志中消失的任务通常是已完成并被移除
+type QuestChange struct {
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title"`
+	Change string `json:"change"`
+}
+
+// InventoryItem 背包或箱子中的一格物品，quality 为 0 普通、1 银星、2 金星、4 铱星
+type InventoryItem struct {
+	Name    string `json:"name"`
+	Stack   int    `json:"stack"`
+	Quality int    `json:"quality"`
+	Type    string `json:"type,omitempty"`
+	ItemID  string `json:"itemId,omitempty"`
+}
+
+// PlayerInventory 玩家（房主或联机的农场帮手）的背包
+type PlayerInventory struct {
+	Player string          `json:"player"`
+	Host   bool            `json:"host"`
+	Items  []InventoryItem `json:"items"`
+}
+
+// ChestInventory 放置在地点中的箱子或冰箱，建筑内部的地点为 "建筑所在地点/建筑内部"
+type ChestInventory struct {
+	Location string          `json:"location"`
+	Kind     string          `json:"kind"` // chest 或 fridge
+	X        int             `json:"x"`
+	Y        int             `json:"y"`
+	Items    []InventoryItem `json:"items"`
+}
+
+// SaveInventory 存档中所有玩家的背包和所有箱子的内容
+type SaveInventory struct {
+	Save    SaveInfo          `json:"save"`
+	Players []PlayerInventory `json:"players"`
+	Chests  []ChestInventory  `json:"chests"`
+}
+
+// SaveRelationships 存档中每个玩家与角色的关系、正在交往或已婚的关系以及孩子
+type SaveRelationships struct {
+	Players  []PlayerRelationships `json:"players"`
+	Couples  []Couple              `json:"couples"`
+	Children []ChildInfo           `json:"children"`
+}
+
+// PlayerRelationships 一个玩家（房主或农场帮手）的所有关系，按好感从高到低排列
+type PlayerRelationships struct {
+	Player      string            `json:"player"`
+	Host        bool              `json:"host"`
+	Spouse      string            `json:"spouse,omitempty"`
+	Friendships []NPCRelationship `json:"friendships"`
+}
+
+// NPCRelationship 与一个角色的关系，每 250 点好感为一颗心
+type NPCRelationship struct {
+	Name          string `json:"name"`
+	Points        int    `json:"points"`
+	Hearts        int    `json:"hearts"`
+	Status        string `json:"status"`
+	GiftsThisWeek int    `json:"giftsThisWeek"`
+	DaysMarried   int    `json:"daysMarried,omitempty"`
+}
+
+// Couple 正在约会、已订婚或已婚的玩家和角色
+type Couple struct {
+	Player string `json:"player"`
+	NPC    string `json:"npc"`
+	Status string `json:"status"`
+	Hearts int    `json:"hearts"`
+}
+
+// ChildInfo 孩子，parent 为孩子所属玩家的名称
+type ChildInfo struct {
+	Name    string `json:"name"`
+	Gender  string `json:"gender,omitempty"` // male 或 female
+	DaysOld int    `json:"daysOld"`
+	Parent  string `json:"parent,omitempty"`
+}
+
+// ItemMatch 搜索到的一格物品及其所在位置，在玩家背包中时 player 不为空
+type ItemMatch struct {
+	InventoryItem
+	Player   string `json:"player,omitempty"`
+	Location string `json:"location,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	X        int    `json:"x,omitempty"`
+	Y        int    `json:"y,omitempty"`
+}
+
+// ItemSearchResult 一个存档中搜索到的物品，total 为匹配物品的总数量
+type ItemSearchResult struct {
+	Library string      `json:"library"`
+	Save    SaveInfo    `json:"save"`
+	Total   int         `json:"total"`
+	Matches []ItemMatch `json:"matches"`
+}
+
+// HistoryRestoreRequest 把存档恢复到历史中的某次快照，commit 可以是提交哈希或标签
+type HistoryRestoreRequest struct {
+	Commit string `json:"commit" binding:"required"`
+}
+
+// BackupTargetInfo 复制目标的概要，不包含凭据
+type BackupTargetInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Location string `json:"location"`
+}
+
+// InboxEntry 收件箱中可以导入的文件或目录
+type InboxEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// InboxInfo 收件箱状态和内容
+type InboxInfo struct {
+	Path          string       `json:"path"`
+	WatchInterval int          `json:"watchInterval"` // 自动导入的检查间隔（秒），0 表示未启用
+	Entries       []InboxEntry `json:"entries"`
+}
+
+// 单个存档的导入状态
+const (
+	ImportStatusImported = "imported"
+	ImportStatusConflict = "conflict"
+	ImportStatusFailed   = "failed"
+)
+
+// ImportSaveResult 压缩包中单个存档的导入结果
+type ImportSaveResult struct {
+	Name       string        `json:"name"`
+	Path       string        `json:"path"`
+	Status     string        `json:"status"` // imported, conflict, failed
+	Overwrite  bool          `json:"overwrite"`
+	BackupPath string        `json:"backupPath,omitempty"`
+	Save       *SaveInfo     `json:"save,omitempty"`
+	Warnings   []string      `json:"warnings,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Conflict   *ConflictInfo `json:"conflict,omitempty"`
+	Verified   bool          `json:"verified"` // 已按导出清单校验
+}
+
+// ImportResult 导入结果，压缩包中的每个存档各有一条记录
+type ImportResult struct {
+	Imported int                `json:"imported"`
+	Failed   int                `json:"failed"`
+	Results  []ImportSaveResult `json:"results"`
+}
+
+// 批量操作中单个存档的状态
+const (
+	BatchStatusExported = "exported"
+	BatchStatusDeleted  = "deleted"
+	BatchStatusNotFound = "not_found"
+	BatchStatusFailed   = "failed"
+	BatchStatusSkipped  = "skipped"
+)
+
+// BatchItemResult 批量操作中单个存档的结果
+type BatchItemResult struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Status     string `json:"status"` // exported, deleted, not_found, failed, skipped
+	BackupPath string `json:"backupPath,omitempty"`
+	Warning    string `json:"warning,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// BatchResult 批量操作的结果，每个请求的存档各有一条记录
+type BatchResult struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// ImportPreview 导入预览，在写入任何文件之前解析出的存档摘要
+type ImportPreview struct {
+	Name       string          `json:"name"`
+	TargetPath string          `json:"targetPath"`
+	IsValid    bool            `json:"isValid"`
+	Save       SaveInfo        `json:"save"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	Errors     []string        `json:"errors,omitempty"`
+	Conflict   *ConflictInfo   `json:"conflict,omitempty"`
+	Manifest   *ExportManifest `json:"manifest,omitempty"` // 压缩包中附带的导出清单
+	Verified   bool            `json:"verified"`           // 文件与清单一致
+}
+
+// ExportManifest 导出时写入每个存档目录的清单，导入时用来发现传输中损坏或缺失的文件
+type ExportManifest struct {
+	Version    int            `json:"version"`
+	SaveID     string         `json:"saveId"`
+	UniqueID   string         `json:"uniqueId,omitempty"` // 游戏的 uniqueIDForThisGame
+	Save       SaveInfo       `json:"save"`
+	ExportedAt *time.Time     `json:"exportedAt,omitempty"`
+	ExportedBy string         `json:"exportedBy,omitempty"`
+	Files      []ManifestFile `json:"files"`
+}
+
+// ManifestFile 清单中的一个文件，路径相对于存档目录
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// RenameSaveRequest 重命名存档请求
+type RenameSaveRequest struct {
+	NewName  string `json:"newName" binding:"required"`
+	FarmName string `json:"farmName,omitempty"`
+}
+
+// TransferRequest 存档转移请求
+type TransferRequest struct {
+	TargetLibrary     string `json:"targetLibrary,omitempty"`
+	TargetPath        string `json:"targetPath,omitempty"`
+	Mode              string `json:"mode"` // copy 或 move
 	OverwriteExisting bool   `json:"overwriteExisting"`
-	BackupExisting    bool   `json:"backupExisting"`
-	SaveName          string `json:"saveName,omitempty"`
+	BackupExisting    *bool  `json:"backupExisting,omitempty"` // 默认备份
+}
+
+// TransferResult 存档转移结果
+type TransferResult struct {
+	Mode       string   `json:"mode"`
+	Source     SaveInfo `json:"source"`
+	Target     SaveInfo `json:"target"`
+	Overwrite  bool     `json:"overwrite"`
+	BackupPath string   `json:"backupPath,omitempty"`
+	Warning    string   `json:"warning,omitempty"`
 }
 
 // SetPathRequest 设置路径请求
 type SetPathRequest struct {
-	Path string `json:"path"`
+	Path    string `json:"path"`
+	Library string `json:"library,omitempty"`
+}
+
+// LibraryRequest 创建或更新存档库请求
+type LibraryRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Path        string `json:"path" binding:"required"`
+	Description string `json:"description,omitempty"`
+	SetDefault  bool   `json:"setDefault"`
 }
 
 // APIResponse 通用API响应
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// builtinPathCandidates 内置的候选存档路径，按优先级排列
+var builtinPathCandidates = []string{
+	"../stardew-multiplayer-docker/valley_saves", // 主要目标路径
+	"../valley_saves", // 备用路径1
+	"./valley_saves",  // 备用路径2
+}
+
+// pathCandidate 候选存档路径及其来源
+type pathCandidate struct {
+	Path   string
+	Source string // env, config, builtin, system
+}
+
+// systemSavePaths 返回当前操作系统下星露谷物语的标准存档位置
+func systemSavePaths() []string {
+	var paths []string
+
+	switch runtime.GOOS {
+	case "windows":
+		// %APPDATA%\StardewValley\Saves
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			paths = append(paths, filepath.Join(appData, "StardewValley", "Saves"))
+		}
+	default:
+		// Linux 和 macOS 都使用 ~/.config/StardewValley/Saves
+		if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" && runtime.GOOS == "linux" {
+			paths = append(paths, filepath.Join(configHome, "StardewValley", "Saves"))
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			paths = append(paths, filepath.Join(home, ".config", "StardewValley", "Saves"))
+		}
+	}
+
+	return paths
+}
+
+// pathCandidates 汇总所有候选路径：环境变量 > 配置文件 > 内置路径 > 系统标准路径，并去重
+func (s *SaveService) pathCandidates() []pathCandidate {
+	var candidates []pathCandidate
+	seen := make(map[string]bool)
+
+	add := func(path, source string) {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return
+		}
+		cleanPath := filepath.Clean(path)
+		if seen[cleanPath] {
+			return
+		}
+		seen[cleanPath] = true
+		candidates = append(candidates, pathCandidate{Path: cleanPath, Source: source})
+	}
+
+	// SAVE_PATH_CANDIDATES 使用系统路径分隔符分隔多个路径
+	for _, path := range filepath.SplitList(os.Getenv("SAVE_PATH_CANDIDATES")) {
+		add(path, "env")
+	}
+
+	s.mu.RLock()
+	configured := append([]string(nil), s.configCandidates...)
+	s.mu.RUnlock()
+	for _, path := range configured {
+		add(path, "config")
+	}
+
+	for _, path := range builtinPathCandidates {
+		add(path, "builtin")
+	}
+
+	for _, path := range systemSavePaths() {
+		add(path, "system")
+	}
+
+	return candidates
+}
+
+// detectPaths 检测所有候选路径的状态及其中有效存档的数量
+func (s *SaveService) detectPaths() []PathCandidateStatus {
+	candidates := s.pathCandidates()
+	statuses := make([]PathCandidateStatus, 0, len(candidates))
+
+	for i, candidate := range candidates {
+		status := PathCandidateStatus{
+			Path:     candidate.Path,
+			Priority: i + 1,
+			Source:   candidate.Source,
+		}
+
+		info, err := os.Stat(candidate.Path)
+		if err != nil {
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+
+		status.Exists = true
+		status.IsDir = info.IsDir()
+		if status.IsDir {
+			status.SaveCount = s.countValidSaves(candidate.Path)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// countValidSaves 统计目录中可以正常解析的存档数量
+func (s *SaveService) countValidSaves(rootPath string) int {
+	saves, err := s.scanSaves(rootPath)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, save := range saves {
+		if save.IsValid {
+			count++
+		}
+	}
+	return count
+}
+
+// detectSavePath 选择默认存档路径：优先选择包含有效存档的候选路径，
+// 其次选择第一个存在的目录，都没有时创建优先级最高的候选路径
+func (s *SaveService) detectSavePath() string {
+	statuses := s.detectPaths()
+
+	for _, status := range statuses {
+		if status.SaveCount > 0 {
+			return status.Path
+		}
+	}
+
+	for _, status := range statuses {
+		if status.IsDir {
+			return status.Path
+		}
+	}
+
+	defaultPath := builtinPathCandidates[0]
+	if len(statuses) > 0 {
+		defaultPath = statuses[0].Path
+	}
+	os.MkdirAll(defaultPath, 0755)
+	return defaultPath
+}
+
+// allowedRoots 返回允许访问的根目录：环境变量 SAVE_ALLOWED_ROOTS 和配置文件 allowedRoots，
+// 两者都未配置时只允许候选存档路径及其子目录
+func (s *SaveService) allowedRoots() []string {
+	roots := filepath.SplitList(os.Getenv("SAVE_ALLOWED_ROOTS"))
+
+	s.mu.RLock()
+	roots = append(roots, s.configRoots...)
+	s.mu.RUnlock()
+
+	var result []string
+	for _, root := range roots {
+		if root = strings.TrimSpace(root); root != "" {
+			result = append(result, filepath.Clean(root))
+		}
+	}
+	if len(result) > 0 {
+		return result
+	}
+
+	for _, candidate := range s.pathCandidates() {
+		result = append(result, candidate.Path)
+	}
+	return result
+}
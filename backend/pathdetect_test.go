@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRootedService 创建只允许 roots 的存档服务
+func newRootedService(t *testing.T, roots ...string) *SaveService {
+	t.Helper()
+	t.Setenv("SAVE_ALLOWED_ROOTS", strings.Join(roots, string(os.PathListSeparator)))
+	return &SaveService{libraries: make(map[string]SaveLibrary), defaultLibrary: defaultLibraryName}
+}
+
+func TestIsWithinDir(t *testing.T) {
+	tests := []struct {
+		root, path string
+		want       bool
+	}{
+		{"/saves", "/saves", true},
+		{"/saves", "/saves/Farm_1", true},
+		{"/saves", "/saves/Farm_1/Farm_1", true},
+		{"/saves", "/saves2", false},
+		{"/saves", "/saves2/Farm_1", false},
+		{"/saves", "/", false},
+		{"/saves", "/other", false},
+		{"/saves", "/saves/../other", false},
+		{"/saves", "/saves/..data", true},
+	}
+	for _, tt := range tests {
+		if got := isWithinDir(tt.root, filepath.Clean(tt.path)); got != tt.want {
+			t.Errorf("isWithinDir(%q, %q) = %v, want %v", tt.root, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsAllowedPath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "saves")
+	sibling := filepath.Join(base, "saves2")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "Farm_1"), sibling, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 根目录内指向外部的符号链接，以及指向根目录内的符号链接
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "Farm_1"), filepath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newRootedService(t, root)
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"root itself", root, true},
+		{"root with trailing separator", root + string(filepath.Separator), true},
+		{"save inside root", filepath.Join(root, "Farm_1"), true},
+		{"symlink to a directory inside root", filepath.Join(root, "inside"), true},
+		{"symlink escaping root", filepath.Join(root, "escape"), false},
+		{"dot-dot escape", root + string(filepath.Separator) + ".." + string(filepath.Separator) + "outside", false},
+		{"sibling with same prefix", sibling, false},
+		{"parent of root", base, false},
+		{"missing path", filepath.Join(root, "missing"), false},
+		{"empty path", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.isAllowedPath(tt.path); got != tt.want {
+				t.Errorf("isAllowedPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if got := s.isValidPath(tt.path); got != tt.want {
+				t.Errorf("isValidPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAllowedPathSymlinkedRoot(t *testing.T) {
+	base := t.TempDir()
+	real := filepath.Join(base, "real")
+	if err := os.MkdirAll(filepath.Join(real, "Farm_1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+
+	// 根目录本身是符号链接时，通过链接和实际路径访问都应被允许
+	s := newRootedService(t, link)
+	for _, path := range []string{link, filepath.Join(link, "Farm_1"), filepath.Join(real, "Farm_1")} {
+		if !s.isAllowedPath(path) {
+			t.Errorf("isAllowedPath(%q) = false, want true", path)
+		}
+	}
+}
+
+func TestAllowedRootsMultiple(t *testing.T) {
+	base := t.TempDir()
+	first := filepath.Join(base, "a")
+	second := filepath.Join(base, "b")
+	for _, dir := range []string{first, second} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newRootedService(t, first, second)
+	s.configRoots = []string{" ", filepath.Join(base, "c") + string(filepath.Separator)}
+	roots := s.allowedRoots()
+	want := []string{first, second, filepath.Join(base, "c")}
+	if len(roots) != len(want) {
+		t.Fatalf("allowedRoots() = %v, want %v", roots, want)
+	}
+	for i := range want {
+		if roots[i] != want[i] {
+			t.Errorf("allowedRoots()[%d] = %q, want %q", i, roots[i], want[i])
+		}
+	}
+	if !s.isAllowedPath(second) {
+		t.Errorf("isAllowedPath(%q) = false, want true", second)
+	}
+}
+
+func TestValidateLibraries(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "saves")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newRootedService(t, root)
+	s.libraries[defaultLibraryName] = SaveLibrary{Name: defaultLibraryName, Path: outside}
+	s.libraries["other"] = SaveLibrary{Name: "other", Path: outside}
+	s.validateLibraries()
+
+	if got := s.libraries[defaultLibraryName].Path; got != root {
+		t.Errorf("default library path = %q, want fallback %q", got, root)
+	}
+	// 其他存档库保持原样，只记录日志
+	if got := s.libraries["other"].Path; got != outside {
+		t.Errorf("other library path = %q, want unchanged %q", got, outside)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// 交往中的关系状态
+var courtingStatuses = map[string]bool{
+	"Dating":   true,
+	"Engaged":  true,
+	"Married":  true,
+	"Roommate": true,
+}
+
+// saveRelationships 整理存档中房主和农场帮手的关系以及孩子
+func saveRelationships(doc *saveDocument) *SaveRelationships {
+	relationships := &SaveRelationships{
+		Players:  make([]PlayerRelationships, 0),
+		Couples:  make([]Couple, 0),
+		Children: make([]ChildInfo, 0),
+	}
+
+	players := append([]*savePlayer{&doc.Player}, documentFarmhands(doc)...)
+	var children []saveCharacter
+	var owners []string // 孩子所在房屋的主人，孩子没有记录父母时使用
+
+	var walk func(location *saveLocation)
+	walk = func(location *saveLocation) {
+		var owner string
+		if location.Name == "FarmHouse" {
+			owner = doc.Player.Name
+		}
+		// 1.5 的农场帮手保存在小屋中，小屋中的孩子属于这个农场帮手
+		if location.Farmhand != nil && location.Farmhand.Name != "" {
+			owner = location.Farmhand.Name
+		}
+
+		for _, character := range location.Characters {
+			if character.Type == "Child" {
+				children = append(children, character)
+				owners = append(owners, owner)
+			}
+		}
+		for _, building := range location.Buildings {
+			if building.Indoors != nil {
+				walk(building.Indoors)
+			}
+		}
+	}
+	for i := range doc.Locations {
+		walk(&doc.Locations[i])
+	}
+
+	names := make(map[int64]string)
+	for i, player := range players {
+		if player.UniqueID != 0 {
+			names[player.UniqueID] = player.Name
+		}
+		relationships.Players = append(relationships.Players, playerRelationships(player, i == 0))
+		for _, friendship := range player.Friendships {
+			if courtingStatuses[friendship.Status] {
+				relationships.Couples = append(relationships.Couples, Couple{
+					Player: player.Name,
+					NPC:    friendship.Name,
+					Status: friendship.Status,
+					Hearts: friendship.Points / friendshipPointsPerHeart,
+				})
+			}
+		}
+	}
+
+	for i, child := range children {
+		parent, ok := names[child.ParentID]
+		if !ok {
+			parent = owners[i]
+		}
+		relationships.Children = append(relationships.Children, ChildInfo{
+			Name:    child.Name,
+			Gender:  characterGender(child.Gender),
+			DaysOld: child.DaysOld,
+			Parent:  parent,
+		})
+	}
+	return relationships
+}
+
+// playerRelationships 一个玩家与所有角色的关系，按好感从高到低排列
+func playerRelationships(player *savePlayer, host bool) PlayerRelationships {
+	result := PlayerRelationships{
+		Player:      player.Name,
+		Host:        host,
+		Spouse:      player.Spouse,
+		Friendships: make([]NPCRelationship, 0, len(player.Friendships)),
+	}
+	for _, friendship := range player.Friendships {
+		result.Friendships = append(result.Friendships, NPCRelationship{
+			Name:          friendship.Name,
+			Points:        friendship.Points,
+			Hearts:        friendship.Points / friendshipPointsPerHeart,
+			Status:        friendship.Status,
+			GiftsThisWeek: friendship.GiftsThisWeek,
+			DaysMarried:   friendship.DaysMarried,
+		})
+	}
+	sort.SliceStable(result.Friendships, func(i, j int) bool {
+		a, b := result.Friendships[i], result.Friendships[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Name < b.Name
+	})
+	return result
+}
+
+// characterGender 统一 1.5（0/1）和 1.6（Male/Female）的性别
+func characterGender(gender string) string {
+	switch strings.ToLower(gender) {
+	case "0", "male":
+		return "male"
+	case "1", "female":
+		return "female"
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 1.6 存档：农场帮手在 farmhands 中，第二个小屋没有人加入过
+const relationships16Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+    <spouse>Harvey</spouse>
+    <friendshipData>
+      <item><key><string>Abigail</string></key><value><Friendship><Points>500</Points><Status>Friendly</Status></Friendship></value></item>
+      <item><key><string>Harvey</string></key><value><Friendship><Points>2600</Points><Status>Married</Status><GiftsThisWeek>1</GiftsThisWeek><DaysMarried>30</DaysMarried></Friendship></value></item>
+    </friendshipData>
+  </player>
+  <farmhands>
+    <Farmer>
+      <name>Bob</name>
+      <UniqueMultiplayerID>200</UniqueMultiplayerID>
+      <friendshipData>
+        <item><key><string>Leah</string></key><value><Friendship><Points>2000</Points><Status>Dating</Status></Friendship></value></item>
+      </friendshipData>
+    </Farmer>
+    <Farmer>
+      <name></name>
+      <UniqueMultiplayerID>300</UniqueMultiplayerID>
+    </Farmer>
+  </farmhands>
+  <locations>
+    <GameLocation xsi:type="FarmHouse">
+      <name>FarmHouse</name>
+      <characters>
+        <NPC xsi:type="Child"><name>Kid</name><Gender>Female</Gender><daysOld>10</daysOld><idOfParent>100</idOfParent></NPC>
+        <NPC xsi:type="Child"><name>Orphan</name><Gender>Male</Gender><daysOld>3</daysOld><idOfParent>0</idOfParent></NPC>
+      </characters>
+    </GameLocation>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building>
+          <indoors xsi:type="Cabin">
+            <name>Cabin</name>
+            <characters>
+              <NPC xsi:type="Child"><name>Junior</name><Gender>Male</Gender><daysOld>5</daysOld><idOfParent>200</idOfParent></NPC>
+            </characters>
+          </indoors>
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+// 1.5 存档：农场帮手保存在小屋中，孩子只能按所在小屋的主人找到父母
+const relationships15Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+  </player>
+  <locations>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building>
+          <indoors xsi:type="Cabin">
+            <name>Cabin</name>
+            <farmhand>
+              <name>Carol</name>
+              <UniqueMultiplayerID>400</UniqueMultiplayerID>
+              <spouse>Sam</spouse>
+              <friendshipData>
+                <item><key><string>Sam</string></key><value><Friendship><Points>2500</Points><Status>Married</Status></Friendship></value></item>
+              </friendshipData>
+            </farmhand>
+            <characters>
+              <NPC xsi:type="Child"><name>Baby</name><Gender>1</Gender><daysOld>2</daysOld></NPC>
+            </characters>
+          </indoors>
+        </Building>
+        <Building>
+          <indoors xsi:type="Cabin">
+            <name>Cabin</name>
+            <farmhand><name></name></farmhand>
+          </indoors>
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+func parseTestRelationships(t *testing.T, data string) *SaveRelationships {
+	t.Helper()
+	doc, err := parseSaveDocument([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saveRelationships(doc)
+}
+
+func playerNames(players []PlayerRelationships) []string {
+	names := make([]string, 0, len(players))
+	for _, player := range players {
+		names = append(names, player.Player)
+	}
+	return names
+}
+
+func TestSaveRelationships16(t *testing.T) {
+	relationships := parseTestRelationships(t, relationships16Save)
+
+	if got, want := playerNames(relationships.Players), []string{"Alice", "Bob"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("players = %v, want %v", got, want)
+	}
+	host := relationships.Players[0]
+	if !host.Host || relationships.Players[1].Host {
+		t.Errorf("host flags = %v, %v, want only the first player", host.Host, relationships.Players[1].Host)
+	}
+	if host.Spouse != "Harvey" {
+		t.Errorf("spouse = %q, want Harvey", host.Spouse)
+	}
+	wantFriendships := []NPCRelationship{
+		{Name: "Harvey", Points: 2600, Hearts: 10, Status: "Married", GiftsThisWeek: 1, DaysMarried: 30},
+		{Name: "Abigail", Points: 500, Hearts: 2, Status: "Friendly"},
+	}
+	if !reflect.DeepEqual(host.Friendships, wantFriendships) {
+		t.Errorf("friendships = %+v, want %+v", host.Friendships, wantFriendships)
+	}
+
+	wantCouples := []Couple{
+		{Player: "Alice", NPC: "Harvey", Status: "Married", Hearts: 10},
+		{Player: "Bob", NPC: "Leah", Status: "Dating", Hearts: 8},
+	}
+	if !reflect.DeepEqual(relationships.Couples, wantCouples) {
+		t.Errorf("couples = %+v, want %+v", relationships.Couples, wantCouples)
+	}
+
+	wantChildren := []ChildInfo{
+		{Name: "Kid", Gender: "female", DaysOld: 10, Parent: "Alice"},
+		// 没有记录父母的孩子归属农舍的主人
+		{Name: "Orphan", Gender: "male", DaysOld: 3, Parent: "Alice"},
+		{Name: "Junior", Gender: "male", DaysOld: 5, Parent: "Bob"},
+	}
+	if !reflect.DeepEqual(relationships.Children, wantChildren) {
+		t.Errorf("children = %+v, want %+v", relationships.Children, wantChildren)
+	}
+}
+
+func TestSaveRelationships15(t *testing.T) {
+	relationships := parseTestRelationships(t, relationships15Save)
+
+	if got, want := playerNames(relationships.Players), []string{"Alice", "Carol"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("players = %v, want %v", got, want)
+	}
+	if carol := relationships.Players[1]; carol.Host || carol.Spouse != "Sam" {
+		t.Errorf("farmhand = %+v, want a non-host married to Sam", carol)
+	}
+	wantCouples := []Couple{{Player: "Carol", NPC: "Sam", Status: "Married", Hearts: 10}}
+	if !reflect.DeepEqual(relationships.Couples, wantCouples) {
+		t.Errorf("couples = %+v, want %+v", relationships.Couples, wantCouples)
+	}
+	wantChildren := []ChildInfo{{Name: "Baby", Gender: "female", DaysOld: 2, Parent: "Carol"}}
+	if !reflect.DeepEqual(relationships.Children, wantChildren) {
+		t.Errorf("children = %+v, want %+v", relationships.Children, wantChildren)
+	}
+}
+
+func TestCharacterGender(t *testing.T) {
+	tests := map[string]string{
+		"0":      "male",
+		"1":      "female",
+		"Male":   "male",
+		"Female": "female",
+		"":       "",
+		"2":      "",
+	}
+	for gender, want := range tests {
+		if got := characterGender(gender); got != want {
+			t.Errorf("characterGender(%q) = %q, want %q", gender, got, want)
+		}
+	}
+}
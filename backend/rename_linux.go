@@ -0,0 +1,26 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// renameNoReplace 重命名文件或目录，目标已存在时返回 os.ErrExist。
+// 检查和重命名由内核一次完成，不会像 os.Rename 那样替换已存在的空目录
+func renameNoReplace(oldPath, newPath string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, newPath, unix.RENAME_NOREPLACE)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.EEXIST):
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrExist}
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EINVAL):
+		// 内核或文件系统不支持时退回到先检查再重命名
+		return renameIfAbsent(oldPath, newPath)
+	}
+	return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: err}
+}
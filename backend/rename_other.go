@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// renameNoReplace 重命名文件或目录，目标已存在时返回 os.ErrExist
+func renameNoReplace(oldPath, newPath string) error {
+	return renameIfAbsent(oldPath, newPath)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupCatalogFile 备份目录中记录每个备份复制状态的文件
+const backupCatalogFile = ".catalog.json"
+
+// primaryBackupStorage 备份首先写入的本地备份目录
+func primaryBackupStorage() *localStorage {
+	return newLocalStorage("local", backupsDir)
+}
+
+// storeBackup 把已经生成的备份文件写入备份目录，然后在后台复制到所有复制目标
+func (s *SaveService) storeBackup(ctx context.Context, srcPath, name string) error {
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := primaryBackupStorage().Put(ctx, name, file, info.Size()); err != nil {
+		return err
+	}
+
+	s.replicateInBackground(name, s.backupTargets())
+	return nil
+}
+
+// replicateInBackground 先把备份在各目标上的状态记为等待中，再在后台逐个复制
+func (s *SaveService) replicateInBackground(name string, targets []BackupStorage) {
+	if len(targets) == 0 {
+		return
+	}
+	for _, target := range targets {
+		s.setReplicaStatus(name, target.Describe().Name, ReplicaStatusPending, "")
+	}
+	go func() {
+		for _, target := range targets {
+			s.replicateBackup(name, target)
+		}
+	}()
+}
+
+// replicateBackup 把备份目录中的备份复制到一个目标并记录结果
+func (s *SaveService) replicateBackup(name string, target BackupStorage) {
+	targetName := target.Describe().Name
+	err := func() error {
+		file, err := os.Open(filepath.Join(backupsDir, name))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		return target.Put(context.Background(), name, file, info.Size())
+	}()
+
+	if err != nil {
+		s.setReplicaStatus(name, targetName, ReplicaStatusFailed, err.Error())
+		s.addLog("replicate", fmt.Sprintf("复制备份失败: %s -> %s", name, targetName), false, err.Error())
+		return
+	}
+	s.setReplicaStatus(name, targetName, ReplicaStatusReplicated, "")
+	s.addLog("replicate", fmt.Sprintf("复制备份: %s -> %s", name, targetName), true, "")
+}
+
+// loadBackupCatalog 读取复制状态，调用方需要持有 catalogMu
+func loadBackupCatalog() map[string][]BackupReplica {
+	catalog := make(map[string][]BackupReplica)
+	data, err := os.ReadFile(filepath.Join(backupsDir, backupCatalogFile))
+	if err != nil {
+		return catalog
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		log.Printf("读取备份复制状态失败: %v", err)
+		return make(map[string][]BackupReplica)
+	}
+	return catalog
+}
+
+// backupCatalog 返回所有备份的复制状态
+func (s *SaveService) backupCatalog() map[string][]BackupReplica {
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+	return loadBackupCatalog()
+}
+
+// setReplicaStatus 更新备份在一个目标上的状态
+func (s *SaveService) setReplicaStatus(name, target, status, errMsg string) {
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	catalog := loadBackupCatalog()
+	replica := BackupReplica{Target: target, Status: status, Error: errMsg, UpdatedAt: time.Now()}
+	replicas := catalog[name]
+	found := false
+	for i := range replicas {
+		if replicas[i].Target == target {
+			replicas[i] = replica
+			found = true
+		}
+	}
+	if !found {
+		replicas = append(replicas, replica)
+		sort.Slice(replicas, func(i, j int) bool {
+			return replicas[i].Target < replicas[j].Target
+		})
+	}
+	catalog[name] = replicas
+
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(backupsDir, 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(backupsDir, backupCatalogFile), data)
+	}
+	if err != nil {
+		log.Printf("保存备份复制状态失败: %v", err)
+	}
+}
+
+// resumeReplication 继续上次退出时没有完成的复制，目标已不在配置中的标记为失败
+func (s *SaveService) resumeReplication() {
+	targets := make(map[string]BackupStorage)
+	for _, target := range s.backupTargets() {
+		targets[target.Describe().Name] = target
+	}
+
+	pending := make(map[string][]BackupStorage)
+	for name, replicas := range s.backupCatalog() {
+		for _, replica := range replicas {
+			if replica.Status != ReplicaStatusPending {
+				continue
+			}
+			target, ok := targets[replica.Target]
+			if !ok {
+				s.setReplicaStatus(name, replica.Target, ReplicaStatusFailed, "复制目标未配置")
+				continue
+			}
+			pending[name] = append(pending[name], target)
+		}
+	}
+
+	for name, targets := range pending {
+		log.Printf("继续复制备份: %s", name)
+		s.replicateInBackground(name, targets)
+	}
+}
+
+// GetBackupTargets 列出配置的备份复制目标
+func (s *SaveService) GetBackupTargets(c *gin.Context) {
+	targets := make([]BackupTargetInfo, 0)
+	for _, target := range s.backupTargets() {
+		targets = append(targets, target.Describe())
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    targets,
+	})
+}
+
+// ReplicateBackup 重新把备份复制到复制目标，默认复制到所有目标，可以用 target 参数指定一个目标
+func (s *SaveService) ReplicateBackup(c *gin.Context) {
+	name, ok := localBackupName(c)
+	if !ok {
+		return
+	}
+
+	targets := s.backupTargets()
+	if targetName := c.Query("target"); targetName != "" {
+		target, ok := s.backupTarget(targetName)
+		if !ok {
+			c.JSON(http.StatusNotFound, APIResponse{
+				Success: false,
+				Error:   "复制目标不存在",
+			})
+			return
+		}
+		targets = []BackupStorage{target}
+	}
+	if len(targets) == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "没有配置备份复制目标",
+		})
+		return
+	}
+
+	s.replicateInBackground(name, targets)
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("正在复制到 %d 个目标", len(targets)),
+		Data:    s.backupCatalog()[name],
+	})
+}
+
+// localBackupName 检查请求中的备份名称并确认备份目录中存在该备份，不存在时直接写入错误响应
+func localBackupName(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	info, err := os.Stat(filepath.Join(backupsDir, name))
+	if !isSafeBackupName(name) || err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "备份不存在",
+		})
+		return "", false
+	}
+	return name, true
+}
+
+// isSafeBackupName 备份名称不能包含路径
+func isSafeBackupName(name string) bool {
+	return name != "" && filepath.Base(name) == name && !strings.ContainsAny(name, `/\`) && isBackupFileName(name)
+}
+
+// downloadBackup 把复制目标中的备份下载到 tempDir，返回本地路径
+func downloadBackup(ctx context.Context, target BackupStorage, name, tempDir string) (string, error) {
+	reader, err := target.Open(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	localPath := filepath.Join(tempDir, name)
+	file, err := os.Create(localPath)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("下载备份失败: %v", err)
+	}
+	return localPath, nil
+}
@@ -0,0 +1,687 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// friendshipPointsPerHeart 每颗心对应的好感点数
+const friendshipPointsPerHeart = 250
+
+// saveDocument 存档差异需要的部分，兼容 1.5 和 1.6 的字段名
+type saveDocument struct {
+	XMLName    xml.Name       `xml:"SaveGame"`
+	Player     savePlayer     `xml:"player"`
+	DayOfMonth int            `xml:"dayOfMonth"`
+	Season     string         `xml:"currentSeason"`
+	Year       int            `xml:"year"`
+	Locations  []saveLocation `xml:"locations>GameLocation"`
+	Farmhands  []savePlayer   `xml:"farmhands>Farmer"`
+}
+
+type savePlayer struct {
+	Name               string       `xml:"name"`
+	FarmName           string       `xml:"farmName"`
+	Money              int64        `xml:"money"`
+	TotalMoneyEarned   int64        `xml:"totalMoneyEarned"`
+	Level              int          `xml:"level"`
+	MillisecondsPlayed int64        `xml:"millisecondsPlayed"`
+	FarmingLevel       int          `xml:"farmingLevel"`
+	MiningLevel        int          `xml:"miningLevel"`
+	CombatLevel        int          `xml:"combatLevel"`
+	ForagingLevel      int          `xml:"foragingLevel"`
+	FishingLevel       int          `xml:"fishingLevel"`
+	LuckLevel          int          `xml:"luckLevel"`
+	HouseUpgradeLevel  int          `xml:"houseUpgradeLevel"`
+	UniqueID           int64        `xml:"UniqueMultiplayerID"`
+	Spouse             string       `xml:"spouse"`
+	Items              []saveItem   `xml:"items>Item"`
+	Friendships        []saveFriend `xml:"friendshipData>item"`
+	Quests             []saveQuest  `xml:"questLog>Quest"`
+	Stats              saveStats    `xml:"stats"`
+	Achievements       []int        `xml:"achievements>int"`
+}
+
+// saveItem 物品，1.5 使用 Stack/name，1.6 使用 stack/name，空格子为 xsi:nil
+type saveItem struct {
+	Type     string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Nil      bool   `xml:"http://www.w3.org/2001/XMLSchema-instance nil,attr"`
+	Name     string `xml:"name"`
+	NameAlt  string `xml:"Name"`
+	Stack    int    `xml:"stack"`
+	StackAlt int    `xml:"Stack"`
+	Quality  int    `xml:"quality"`
+	ItemID   string `xml:"itemId"`
+	SheetID  string `xml:"parentSheetIndex"`
+}
+
+// displayName 物品名称，没有名称时使用物品编号
+func (i saveItem) displayName() string {
+	switch {
+	case i.Name != "":
+		return i.Name
+	case i.NameAlt != "":
+		return i.NameAlt
+	case i.ItemID != "":
+		return i.ItemID
+	case i.SheetID != "":
+		return "#" + i.SheetID
+	}
+	return i.Type
+}
+
+// count 物品数量，工具等没有数量的物品按 1 计
+func (i saveItem) count() int {
+	if i.Stack > 0 {
+		return i.Stack
+	}
+	if i.StackAlt > 0 {
+		return i.StackAlt
+	}
+	return 1
+}
+
+// saveFriend 玩家与一个角色的关系，Status 为 Friendly、Dating、Engaged、Married、Divorced 等
+type saveFriend struct {
+	Name          string `xml:"key>string"`
+	Points        int    `xml:"value>Friendship>Points"`
+	Status        string `xml:"value>Friendship>Status"`
+	GiftsThisWeek int    `xml:"value>Friendship>GiftsThisWeek"`
+	DaysMarried   int    `xml:"value>Friendship>DaysMarried"`
+}
+
+// saveQuest 任务，1.5 使用 questTitle，1.6 使用 _questTitle
+type saveQuest struct {
+	ID        string `xml:"id"`
+	Title     string `xml:"questTitle"`
+	TitleAlt  string `xml:"_questTitle"`
+	Completed bool   `xml:"completed"`
+}
+
+func (q saveQuest) key() string {
+	if q.ID != "" && q.ID != "0" {
+		return q.ID
+	}
+	return q.title()
+}
+
+func (q saveQuest) title() string {
+	switch {
+	case q.Title != "":
+		return q.Title
+	case q.TitleAlt != "":
+		return q.TitleAlt
+	}
+	return "任务 " + q.ID
+}
+
+// saveStats 1.5 的统计直接是字段，1.6 放在 Values 字典中
+type saveStats struct {
+	QuestsCompleted int `xml:"questsCompleted"`
+	Values          []struct {
+		Key   string `xml:"key>string"`
+		Value int    `xml:"value>unsignedInt"`
+	} `xml:"Values>item"`
+}
+
+func (s saveStats) questsCompleted() int {
+	for _, value := range s.Values {
+		if value.Key == "questsCompleted" {
+			return value.Value
+		}
+	}
+	return s.QuestsCompleted
+}
+
+// saveLocation 地点。建筑内部（棚屋、小屋等）同样是地点，位于建筑的 indoors 中
+type saveLocation struct {
+	Type       string            `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Name       string            `xml:"name"`
+	Buildings  []saveBuilding    `xml:"buildings>Building"`
+	Objects    []saveObjectEntry `xml:"objects>item"`
+	Fridge     []saveItem        `xml:"fridge>items>Item"`
+	Characters []saveCharacter   `xml:"characters>NPC"`
+	Farmhand   *savePlayer       `xml:"farmhand"` // 1.5 的农场帮手保存在小屋中
+}
+
+// saveCharacter 地点中的角色，孩子的 xsi:type 为 Child。1.5 的性别为 0/1，1.6 为 Male/Female
+type saveCharacter struct {
+	Type     string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Name     string `xml:"name"`
+	Gender   string `xml:"Gender"`
+	DaysOld  int    `xml:"daysOld"`
+	ParentID int64  `xml:"idOfParent"`
+}
+
+// saveObjectEntry 地点中放置的物品，按坐标保存
+type saveObjectEntry struct {
+	X     int        `xml:"key>Vector2>X"`
+	Y     int        `xml:"key>Vector2>Y"`
+	Value saveObject `xml:"value>Object"`
+}
+
+// saveObject 放置的物品，箱子的内容在 items 中
+type saveObject struct {
+	Type  string     `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Name  string     `xml:"name"`
+	Items []saveItem `xml:"items>Item"`
+}
+
+type saveBuilding struct {
+	Type             string        `xml:"buildingType"`
+	TileX            int           `xml:"tileX"`
+	TileY            int           `xml:"tileY"`
+	DaysConstruction int           `xml:"daysOfConstructionLeft"`
+	DaysUpgrade      int           `xml:"daysUntilUpgrade"`
+	Indoors          *saveLocation `xml:"indoors"`
+}
+
+// parseSaveDocument 解析主存档文件
+func parseSaveDocument(data []byte) (*saveDocument, error) {
+	var doc saveDocument
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析存档失败: %v", err)
+	}
+	return &doc, nil
+}
+
+// documentSaveInfo 存档摘要，与存档列表中的字段一致
+func (s *SaveService) documentSaveInfo(doc *saveDocument) SaveInfo {
+	var info SaveInfo
+	s.fillSaveInfo(&info, &StardewSaveGame{
+		Player: Player{
+			Name:               doc.Player.Name,
+			FarmName:           doc.Player.FarmName,
+			Money:              doc.Player.Money,
+			Level:              doc.Player.Level,
+			MillisecondsPlayed: doc.Player.MillisecondsPlayed,
+		},
+		DayOfMonth: doc.DayOfMonth,
+		Season:     doc.Season,
+		Year:       doc.Year,
+	})
+	return info
+}
+
+// diffSaveDocuments 比较两个存档，结果为 target 相对于 base 的变化
+func (s *SaveService) diffSaveDocuments(base, target *saveDocument) *SaveDiff {
+	diff := &SaveDiff{
+		Base:        SaveVersionInfo{Save: s.documentSaveInfo(base)},
+		Target:      SaveVersionInfo{Save: s.documentSaveInfo(target)},
+		Summary:     make([]string, 0),
+		Fields:      diffSaveFields(base, target),
+		Inventory:   diffInventory(base.Player.Items, target.Player.Items),
+		Friendships: diffFriendships(base.Player.Friendships, target.Player.Friendships),
+		Buildings:   diffBuildings(base.Locations, target.Locations),
+		Quests:      diffQuests(base.Player.Quests, target.Player.Quests),
+		Farmhands:   diffFarmhands(documentFarmhands(base), documentFarmhands(target)),
+	}
+	diff.Identical = len(diff.Fields) == 0 && len(diff.Inventory) == 0 && len(diff.Friendships) == 0 &&
+		len(diff.Buildings) == 0 && len(diff.Quests) == 0 && len(diff.Farmhands) == 0
+	diff.Summary = summarizeSaveDiff(diff)
+	return diff
+}
+
+// formatGameDate 游戏内日期
+func formatGameDate(year int, season string, day int) string {
+	return fmt.Sprintf("第 %d 年 %s 第 %d 天", year, season, day)
+}
+
+// diffSaveFields 比较游戏日期和房主的金钱、技能等级等数值
+func diffSaveFields(base, target *saveDocument) []SaveFieldChange {
+	changes := make([]SaveFieldChange, 0)
+	if oldDate, newDate := formatGameDate(base.Year, base.Season, base.DayOfMonth), formatGameDate(target.Year, target.Season, target.DayOfMonth); oldDate != newDate {
+		changes = append(changes, SaveFieldChange{Field: "date", Label: "日期", Old: oldDate, New: newDate})
+	}
+	return append(changes, diffPlayerFields(&base.Player, &target.Player)...)
+}
+
+// diffPlayerFields 比较一个玩家的金钱、技能等级等数值
+func diffPlayerFields(base, target *savePlayer) []SaveFieldChange {
+	changes := make([]SaveFieldChange, 0)
+	numbers := []struct {
+		field, label string
+		old, new     int64
+	}{
+		{"money", "金钱", base.Money, target.Money},
+		{"totalMoneyEarned", "累计收入", base.TotalMoneyEarned, target.TotalMoneyEarned},
+		{"farmingLevel", "耕种等级", int64(base.FarmingLevel), int64(target.FarmingLevel)},
+		{"miningLevel", "采矿等级", int64(base.MiningLevel), int64(target.MiningLevel)},
+		{"combatLevel", "战斗等级", int64(base.CombatLevel), int64(target.CombatLevel)},
+		{"foragingLevel", "采集等级", int64(base.ForagingLevel), int64(target.ForagingLevel)},
+		{"fishingLevel", "钓鱼等级", int64(base.FishingLevel), int64(target.FishingLevel)},
+		{"luckLevel", "幸运等级", int64(base.LuckLevel), int64(target.LuckLevel)},
+		{"houseUpgradeLevel", "房屋等级", int64(base.HouseUpgradeLevel), int64(target.HouseUpgradeLevel)},
+		{"questsCompleted", "完成任务数", int64(base.Stats.questsCompleted()), int64(target.Stats.questsCompleted())},
+		{"achievements", "成就数", int64(len(base.Achievements)), int64(len(target.Achievements))},
+		{"millisecondsPlayed", "游戏时长（毫秒）", base.MillisecondsPlayed, target.MillisecondsPlayed},
+	}
+	for _, n := range numbers {
+		if n.old != n.new {
+			changes = append(changes, SaveFieldChange{Field: n.field, Label: n.label, Old: n.old, New: n.new, Delta: n.new - n.old})
+		}
+	}
+
+	strs := []struct {
+		field, label string
+		old, new     string
+	}{
+		{"playerName", "玩家", base.Name, target.Name},
+		{"farmName", "农场", base.FarmName, target.FarmName},
+		{"spouse", "配偶", base.Spouse, target.Spouse},
+	}
+	for _, str := range strs {
+		if str.old != str.new {
+			changes = append(changes, SaveFieldChange{Field: str.field, Label: str.label, Old: str.old, New: str.new})
+		}
+	}
+	return changes
+}
+
+// documentFarmhands 存档中的农场帮手，1.6 保存在 farmhands 中，1.5 保存在小屋中。
+// 没有人加入过的小屋也有一个没有名字的农场帮手，这些会被跳过
+func documentFarmhands(doc *saveDocument) []*savePlayer {
+	farmhands := make([]*savePlayer, 0)
+	for i := range doc.Farmhands {
+		if doc.Farmhands[i].Name != "" {
+			farmhands = append(farmhands, &doc.Farmhands[i])
+		}
+	}
+
+	var walk func(location *saveLocation)
+	walk = func(location *saveLocation) {
+		if location.Farmhand != nil && location.Farmhand.Name != "" {
+			farmhands = append(farmhands, location.Farmhand)
+		}
+		for _, building := range location.Buildings {
+			if building.Indoors != nil {
+				walk(building.Indoors)
+			}
+		}
+	}
+	for i := range doc.Locations {
+		walk(&doc.Locations[i])
+	}
+	return farmhands
+}
+
+// diffFarmhands 按 UniqueMultiplayerID 比较每个农场帮手，只返回有变化的农场帮手
+func diffFarmhands(base, target []*savePlayer) []FarmhandDiff {
+	old := make(map[int64]*savePlayer)
+	for _, farmhand := range base {
+		old[farmhand.UniqueID] = farmhand
+	}
+
+	diffs := make([]FarmhandDiff, 0)
+	seen := make(map[int64]bool)
+	for _, farmhand := range target {
+		seen[farmhand.UniqueID] = true
+		diff := FarmhandDiff{
+			Player:      farmhand.Name,
+			UniqueID:    farmhand.UniqueID,
+			Change:      ChangeAdded,
+			Fields:      make([]SaveFieldChange, 0),
+			Inventory:   make([]InventoryChange, 0),
+			Friendships: make([]FriendshipChange, 0),
+			Quests:      make([]QuestChange, 0),
+		}
+		if previous, ok := old[farmhand.UniqueID]; ok {
+			diff.Change = ChangeChanged
+			diff.Fields = diffPlayerFields(previous, farmhand)
+			diff.Inventory = diffInventory(previous.Items, farmhand.Items)
+			diff.Friendships = diffFriendships(previous.Friendships, farmhand.Friendships)
+			diff.Quests = diffQuests(previous.Quests, farmhand.Quests)
+			if len(diff.Fields) == 0 && len(diff.Inventory) == 0 && len(diff.Friendships) == 0 && len(diff.Quests) == 0 {
+				continue
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	for _, farmhand := range base {
+		if !seen[farmhand.UniqueID] {
+			diffs = append(diffs, FarmhandDiff{
+				Player:      farmhand.Name,
+				UniqueID:    farmhand.UniqueID,
+				Change:      ChangeRemoved,
+				Fields:      make([]SaveFieldChange, 0),
+				Inventory:   make([]InventoryChange, 0),
+				Friendships: make([]FriendshipChange, 0),
+				Quests:      make([]QuestChange, 0),
+			})
+		}
+	}
+	return diffs
+}
+
+// itemKey 同名同品质的物品合并计数
+type itemKey struct {
+	name    string
+	quality int
+}
+
+func countItems(items []saveItem) map[itemKey]int {
+	counts := make(map[itemKey]int)
+	for _, item := range items {
+		if item.Nil {
+			continue
+		}
+		counts[itemKey{item.displayName(), item.Quality}] += item.count()
+	}
+	return counts
+}
+
+// diffInventory 比较背包中每种物品的数量
+func diffInventory(base, target []saveItem) []InventoryChange {
+	oldCounts := countItems(base)
+	newCounts := countItems(target)
+
+	changes := make([]InventoryChange, 0)
+	for key, old := range oldCounts {
+		if newCounts[key] != old {
+			changes = append(changes, newInventoryChange(key, old, newCounts[key]))
+		}
+	}
+	for key, count := range newCounts {
+		if _, ok := oldCounts[key]; !ok {
+			changes = append(changes, newInventoryChange(key, 0, count))
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Quality < changes[j].Quality
+	})
+	return changes
+}
+
+func newInventoryChange(key itemKey, old, new int) InventoryChange {
+	change := ChangeChanged
+	switch {
+	case old == 0:
+		change = ChangeAdded
+	case new == 0:
+		change = ChangeRemoved
+	}
+	return InventoryChange{Name: key.name, Quality: key.quality, Old: old, New: new, Delta: new - old, Change: change}
+}
+
+// diffFriendships 比较好感度和关系状态
+func diffFriendships(base, target []saveFriend) []FriendshipChange {
+	old := make(map[string]saveFriend)
+	for _, friend := range base {
+		old[friend.Name] = friend
+	}
+
+	changes := make([]FriendshipChange, 0)
+	seen := make(map[string]bool)
+	for _, friend := range target {
+		seen[friend.Name] = true
+		previous, existed := old[friend.Name]
+		if existed && previous.Points == friend.Points && previous.Status == friend.Status {
+			continue
+		}
+		change := ChangeChanged
+		if !existed {
+			change = ChangeAdded
+		}
+		changes = append(changes, FriendshipChange{
+			Name:      friend.Name,
+			OldPoints: previous.Points,
+			NewPoints: friend.Points,
+			OldHearts: previous.Points / friendshipPointsPerHeart,
+			NewHearts: friend.Points / friendshipPointsPerHeart,
+			OldStatus: previous.Status,
+			NewStatus: friend.Status,
+			Change:    change,
+		})
+	}
+	for _, friend := range base {
+		if !seen[friend.Name] {
+			changes = append(changes, FriendshipChange{
+				Name:      friend.Name,
+				OldPoints: friend.Points,
+				OldHearts: friend.Points / friendshipPointsPerHeart,
+				OldStatus: friend.Status,
+				Change:    ChangeRemoved,
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// buildingKey 建筑按所在地点和坐标对应，同一位置的类型变化视为升级
+type buildingKey struct {
+	location string
+	x, y     int
+}
+
+func indexBuildings(locations []saveLocation) map[buildingKey]saveBuilding {
+	buildings := make(map[buildingKey]saveBuilding)
+	for _, location := range locations {
+		name := location.Name
+		if name == "" {
+			name = location.Type
+		}
+		for _, building := range location.Buildings {
+			buildings[buildingKey{name, building.TileX, building.TileY}] = building
+		}
+	}
+	return buildings
+}
+
+// diffBuildings 比较建造、拆除、升级和完工的建筑
+func diffBuildings(base, target []saveLocation) []BuildingChange {
+	old := indexBuildings(base)
+	current := indexBuildings(target)
+
+	changes := make([]BuildingChange, 0)
+	for key, building := range current {
+		change := BuildingChange{Type: building.Type, Location: key.location, X: key.x, Y: key.y}
+		previous, existed := old[key]
+		switch {
+		case !existed:
+			change.Change = ChangeAdded
+		case previous.Type != building.Type:
+			change.Change = ChangeUpgraded
+			change.OldType = previous.Type
+		case previous.DaysConstruction > 0 && building.DaysConstruction == 0,
+			previous.DaysUpgrade > 0 && building.DaysUpgrade == 0:
+			change.Change = ChangeCompleted
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	for key, building := range old {
+		if _, ok := current[key]; !ok {
+			changes = append(changes, BuildingChange{Type: building.Type, Location: key.location, X: key.x, Y: key.y, Change: ChangeRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+	return changes
+}
+
+// diffQuests 比较任务日志：新接的任务、标记为完成的任务和从日志中移除的任务
+func diffQuests(base, target []saveQuest) []QuestChange {
+	old := make(map[string]saveQuest)
+	for _, quest := range base {
+		old[quest.key()] = quest
+	}
+
+	changes := make([]QuestChange, 0)
+	seen := make(map[string]bool)
+	for _, quest := range target {
+		key := quest.key()
+		seen[key] = true
+		previous, existed := old[key]
+		switch {
+		case !existed:
+			changes = append(changes, QuestChange{ID: quest.ID, Title: quest.title(), Change: ChangeAdded})
+		case quest.Completed && !previous.Completed:
+			changes = append(changes, QuestChange{ID: quest.ID, Title: quest.title(), Change: ChangeCompleted})
+		}
+	}
+	for _, quest := range base {
+		if !seen[quest.key()] {
+			changes = append(changes, QuestChange{ID: quest.ID, Title: quest.title(), Change: ChangeRemoved})
+		}
+	}
+	return changes
+}
+
+// friendshipStatusText 关系状态的中文名称
+func friendshipStatusText(status string) string {
+	switch status {
+	case "Friendly":
+		return "朋友"
+	case "Dating":
+		return "约会中"
+	case "Engaged":
+		return "已订婚"
+	case "Married":
+		return "已婚"
+	case "Divorced":
+		return "已离婚"
+	case "Roommate":
+		return "室友"
+	}
+	return status
+}
+
+// formatSigned 带符号的数字
+func formatSigned(n int64) string {
+	if n > 0 {
+		return "+" + strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// summaryValue 说明中显示的值，空字符串显示为“无”
+func summaryValue(value interface{}) string {
+	if value == "" {
+		return "无"
+	}
+	return fmt.Sprint(value)
+}
+
+// summarizeSaveDiff 把差异整理成便于阅读的说明，农场帮手的变化以其名称开头
+func summarizeSaveDiff(diff *SaveDiff) []string {
+	summary := summarizePlayerChanges(diff.Fields, diff.Inventory, diff.Friendships)
+
+	for _, building := range diff.Buildings {
+		where := fmt.Sprintf("%s (%d, %d)", building.Location, building.X, building.Y)
+		switch building.Change {
+		case ChangeAdded:
+			summary = append(summary, fmt.Sprintf("建筑: 建造 %s，%s", building.Type, where))
+		case ChangeRemoved:
+			summary = append(summary, fmt.Sprintf("建筑: 拆除 %s，%s", building.Type, where))
+		case ChangeUpgraded:
+			summary = append(summary, fmt.Sprintf("建筑: %s 升级为 %s，%s", building.OldType, building.Type, where))
+		case ChangeCompleted:
+			summary = append(summary, fmt.Sprintf("建筑: %s 完工，%s", building.Type, where))
+		}
+	}
+
+	summary = append(summary, summarizeQuests(diff.Quests)...)
+
+	for _, farmhand := range diff.Farmhands {
+		switch farmhand.Change {
+		case ChangeAdded:
+			summary = append(summary, fmt.Sprintf("农场帮手: %s 加入了农场", farmhand.Player))
+		case ChangeRemoved:
+			summary = append(summary, fmt.Sprintf("农场帮手: %s 不在存档中了", farmhand.Player))
+		default:
+			lines := summarizePlayerChanges(farmhand.Fields, farmhand.Inventory, farmhand.Friendships)
+			for _, line := range append(lines, summarizeQuests(farmhand.Quests)...) {
+				summary = append(summary, fmt.Sprintf("[%s] %s", farmhand.Player, line))
+			}
+		}
+	}
+
+	if diff.Identical {
+		summary = append(summary, "游戏内容没有变化")
+	}
+	return summary
+}
+
+// summarizePlayerChanges 一个玩家的数值、背包和关系变化的说明
+func summarizePlayerChanges(fields []SaveFieldChange, inventory []InventoryChange, friendships []FriendshipChange) []string {
+	summary := make([]string, 0)
+	for _, field := range fields {
+		if field.Field == "millisecondsPlayed" {
+			continue
+		}
+		line := fmt.Sprintf("%s: %s → %s", field.Label, summaryValue(field.Old), summaryValue(field.New))
+		if field.Delta != 0 {
+			line += fmt.Sprintf("（%s）", formatSigned(field.Delta))
+		}
+		summary = append(summary, line)
+	}
+
+	qualities := []string{"", "银星", "金星", "", "铱星"}
+	for _, item := range inventory {
+		name := item.Name
+		if item.Quality > 0 && item.Quality < len(qualities) && qualities[item.Quality] != "" {
+			name = qualities[item.Quality] + name
+		}
+		switch item.Change {
+		case ChangeAdded:
+			summary = append(summary, fmt.Sprintf("背包: 新增 %s ×%d", name, item.New))
+		case ChangeRemoved:
+			summary = append(summary, fmt.Sprintf("背包: 失去 %s ×%d", name, item.Old))
+		default:
+			summary = append(summary, fmt.Sprintf("背包: %s %d → %d（%s）", name, item.Old, item.New, formatSigned(int64(item.Delta))))
+		}
+	}
+
+	for _, friend := range friendships {
+		switch {
+		case friend.Change == ChangeAdded:
+			summary = append(summary, fmt.Sprintf("关系: 认识了 %s（%d 心）", friend.Name, friend.NewHearts))
+		case friend.Change == ChangeRemoved:
+			summary = append(summary, fmt.Sprintf("关系: 不再记录 %s", friend.Name))
+		default:
+			if friend.OldHearts != friend.NewHearts {
+				summary = append(summary, fmt.Sprintf("关系: %s %d → %d 心", friend.Name, friend.OldHearts, friend.NewHearts))
+			} else if friend.OldPoints != friend.NewPoints && friend.OldStatus == friend.NewStatus {
+				summary = append(summary, fmt.Sprintf("关系: %s 好感 %s", friend.Name, formatSigned(int64(friend.NewPoints-friend.OldPoints))))
+			}
+			if friend.OldStatus != friend.NewStatus {
+				summary = append(summary, fmt.Sprintf("关系: %s %s → %s", friend.Name, friendshipStatusText(friend.OldStatus), friendshipStatusText(friend.NewStatus)))
+			}
+		}
+	}
+	return summary
+}
+
+// summarizeQuests 任务日志变化的说明
+func summarizeQuests(quests []QuestChange) []string {
+	summary := make([]string, 0)
+	for _, quest := range quests {
+		switch quest.Change {
+		case ChangeAdded:
+			summary = append(summary, "任务: 接受 "+quest.Title)
+		case ChangeCompleted:
+			summary = append(summary, "任务: 完成 "+quest.Title)
+		case ChangeRemoved:
+			summary = append(summary, "任务: "+quest.Title+" 已从任务日志中移除（通常是已完成）")
+		}
+	}
+	return summary
+}
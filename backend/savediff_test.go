@@ -0,0 +1,389 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 1.6 存档：Bob 和 Dave 已经加入，UniqueMultiplayerID 为 400 的小屋还没有人加入过
+const diffBase16Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <farmName>Sunny</farmName>
+    <money>500</money>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+    <items>
+      <Item xsi:type="Object"><name>Parsnip</name><stack>5</stack></Item>
+    </items>
+    <friendshipData>
+      <item><key><string>Abigail</string></key><value><Friendship><Points>500</Points><Status>Friendly</Status></Friendship></value></item>
+    </friendshipData>
+    <questLog>
+      <Quest><id>1</id><_questTitle>Introductions</_questTitle><completed>false</completed></Quest>
+    </questLog>
+  </player>
+  <dayOfMonth>1</dayOfMonth>
+  <currentSeason>spring</currentSeason>
+  <year>1</year>
+  <farmhands>
+    <Farmer>
+      <name>Bob</name>
+      <money>100</money>
+      <UniqueMultiplayerID>200</UniqueMultiplayerID>
+      <items>
+        <Item xsi:type="Object"><name>Wood</name><stack>10</stack></Item>
+      </items>
+      <friendshipData>
+        <item><key><string>Leah</string></key><value><Friendship><Points>1000</Points><Status>Friendly</Status></Friendship></value></item>
+      </friendshipData>
+    </Farmer>
+    <Farmer>
+      <name>Dave</name>
+      <money>50</money>
+      <UniqueMultiplayerID>300</UniqueMultiplayerID>
+    </Farmer>
+    <Farmer>
+      <name></name>
+      <UniqueMultiplayerID>400</UniqueMultiplayerID>
+      <items>
+        <Item xsi:type="Tool"><name>Axe</name></Item>
+      </items>
+    </Farmer>
+  </farmhands>
+  <locations>
+    <GameLocation xsi:type="Farm"><name>Farm</name></GameLocation>
+  </locations>
+</SaveGame>`
+
+// 第二天：房主和 Bob 都有变化，Dave 没有变化（顺序不同），Erin 加入了空的小屋
+const diffTarget16Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <farmName>Sunny</farmName>
+    <money>800</money>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+    <items>
+      <Item xsi:type="Object"><name>Parsnip</name><stack>2</stack></Item>
+    </items>
+    <friendshipData>
+      <item><key><string>Abigail</string></key><value><Friendship><Points>750</Points><Status>Dating</Status></Friendship></value></item>
+    </friendshipData>
+    <questLog>
+      <Quest><id>1</id><_questTitle>Introductions</_questTitle><completed>true</completed></Quest>
+    </questLog>
+  </player>
+  <dayOfMonth>2</dayOfMonth>
+  <currentSeason>spring</currentSeason>
+  <year>1</year>
+  <farmhands>
+    <Farmer>
+      <name>Dave</name>
+      <money>50</money>
+      <UniqueMultiplayerID>300</UniqueMultiplayerID>
+    </Farmer>
+    <Farmer>
+      <name>Bob</name>
+      <money>250</money>
+      <UniqueMultiplayerID>200</UniqueMultiplayerID>
+      <items>
+        <Item xsi:type="Object"><name>Wood</name><stack>30</stack></Item>
+      </items>
+      <friendshipData>
+        <item><key><string>Leah</string></key><value><Friendship><Points>1250</Points><Status>Friendly</Status></Friendship></value></item>
+      </friendshipData>
+      <questLog>
+        <Quest><id>2</id><_questTitle>Rat Problem</_questTitle></Quest>
+      </questLog>
+    </Farmer>
+    <Farmer>
+      <name>Erin</name>
+      <UniqueMultiplayerID>400</UniqueMultiplayerID>
+      <items>
+        <Item xsi:type="Tool"><name>Axe</name></Item>
+      </items>
+    </Farmer>
+  </farmhands>
+  <locations>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building><buildingType>Coop</buildingType><tileX>10</tileX><tileY>12</tileY></Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+// 1.5 存档：农场帮手保存在小屋中，Carol 在第一个小屋，Frank 在第二个小屋
+const diffBase15Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+  </player>
+  <dayOfMonth>5</dayOfMonth>
+  <currentSeason>summer</currentSeason>
+  <year>2</year>
+  <locations>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building>
+          <buildingType>Log Cabin</buildingType>
+          <indoors xsi:type="Cabin">
+            <farmhand>
+              <name>Carol</name>
+              <money>10</money>
+              <UniqueMultiplayerID>500</UniqueMultiplayerID>
+              <items>
+                <Item xsi:type="Object"><name>Copper Ore</name><Stack>3</Stack></Item>
+              </items>
+            </farmhand>
+          </indoors>
+        </Building>
+        <Building>
+          <buildingType>Log Cabin</buildingType>
+          <tileX>5</tileX>
+          <indoors xsi:type="Cabin">
+            <farmhand>
+              <name>Frank</name>
+              <UniqueMultiplayerID>600</UniqueMultiplayerID>
+            </farmhand>
+          </indoors>
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+// Carol 有变化，Frank 的小屋被拆除
+const diffTarget15Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+  </player>
+  <dayOfMonth>5</dayOfMonth>
+  <currentSeason>summer</currentSeason>
+  <year>2</year>
+  <locations>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building>
+          <buildingType>Log Cabin</buildingType>
+          <indoors xsi:type="Cabin">
+            <farmhand>
+              <name>Carol</name>
+              <money>20</money>
+              <UniqueMultiplayerID>500</UniqueMultiplayerID>
+              <items>
+                <Item xsi:type="Object"><name>Copper Ore</name><Stack>8</Stack></Item>
+              </items>
+            </farmhand>
+          </indoors>
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+// Carol 在 1.6 中的同一天，农场帮手移到了 farmhands 中
+const diffCarol16Save = `<?xml version="1.0" encoding="utf-8"?>
+<SaveGame xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <player>
+    <name>Alice</name>
+    <UniqueMultiplayerID>100</UniqueMultiplayerID>
+  </player>
+  <dayOfMonth>5</dayOfMonth>
+  <currentSeason>summer</currentSeason>
+  <year>2</year>
+  <farmhands>
+    <Farmer>
+      <name>Carol</name>
+      <money>20</money>
+      <UniqueMultiplayerID>500</UniqueMultiplayerID>
+      <items>
+        <Item xsi:type="Object"><name>Copper Ore</name><stack>8</stack></Item>
+      </items>
+    </Farmer>
+  </farmhands>
+  <locations>
+    <GameLocation xsi:type="Farm">
+      <name>Farm</name>
+      <buildings>
+        <Building>
+          <buildingType>Cabin</buildingType>
+          <indoors xsi:type="Cabin" />
+        </Building>
+      </buildings>
+    </GameLocation>
+  </locations>
+</SaveGame>`
+
+func diffTestDocuments(t *testing.T, base, target string) *SaveDiff {
+	t.Helper()
+	s := &SaveService{}
+	return s.diffSaveDocuments(parseTestDocument(t, base), parseTestDocument(t, target))
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDiffSaveDocuments16(t *testing.T) {
+	diff := diffTestDocuments(t, diffBase16Save, diffTarget16Save)
+	if diff.Identical {
+		t.Fatal("diff is identical")
+	}
+
+	wantFields := []SaveFieldChange{
+		{Field: "date", Label: "日期", Old: "第 1 年 spring 第 1 天", New: "第 1 年 spring 第 2 天"},
+		{Field: "money", Label: "金钱", Old: int64(500), New: int64(800), Delta: 300},
+	}
+	if !reflect.DeepEqual(diff.Fields, wantFields) {
+		t.Errorf("fields = %+v, want %+v", diff.Fields, wantFields)
+	}
+	wantInventory := []InventoryChange{{Name: "Parsnip", Old: 5, New: 2, Delta: -3, Change: ChangeChanged}}
+	if !reflect.DeepEqual(diff.Inventory, wantInventory) {
+		t.Errorf("inventory = %+v, want %+v", diff.Inventory, wantInventory)
+	}
+	wantFriendships := []FriendshipChange{{Name: "Abigail", OldPoints: 500, NewPoints: 750, OldHearts: 2, NewHearts: 3, OldStatus: "Friendly", NewStatus: "Dating", Change: ChangeChanged}}
+	if !reflect.DeepEqual(diff.Friendships, wantFriendships) {
+		t.Errorf("friendships = %+v, want %+v", diff.Friendships, wantFriendships)
+	}
+	wantBuildings := []BuildingChange{{Type: "Coop", Location: "Farm", X: 10, Y: 12, Change: ChangeAdded}}
+	if !reflect.DeepEqual(diff.Buildings, wantBuildings) {
+		t.Errorf("buildings = %+v, want %+v", diff.Buildings, wantBuildings)
+	}
+	wantQuests := []QuestChange{{ID: "1", Title: "Introductions", Change: ChangeCompleted}}
+	if !reflect.DeepEqual(diff.Quests, wantQuests) {
+		t.Errorf("quests = %+v, want %+v", diff.Quests, wantQuests)
+	}
+
+	// Dave 没有变化，不在结果中；空的小屋有人加入后视为新加入的农场帮手
+	wantFarmhands := []FarmhandDiff{
+		{
+			Player:   "Bob",
+			UniqueID: 200,
+			Change:   ChangeChanged,
+			Fields:   []SaveFieldChange{{Field: "money", Label: "金钱", Old: int64(100), New: int64(250), Delta: 150}},
+			Inventory: []InventoryChange{
+				{Name: "Wood", Old: 10, New: 30, Delta: 20, Change: ChangeChanged},
+			},
+			Friendships: []FriendshipChange{
+				{Name: "Leah", OldPoints: 1000, NewPoints: 1250, OldHearts: 4, NewHearts: 5, OldStatus: "Friendly", NewStatus: "Friendly", Change: ChangeChanged},
+			},
+			Quests: []QuestChange{{ID: "2", Title: "Rat Problem", Change: ChangeAdded}},
+		},
+		{
+			Player:      "Erin",
+			UniqueID:    400,
+			Change:      ChangeAdded,
+			Fields:      []SaveFieldChange{},
+			Inventory:   []InventoryChange{},
+			Friendships: []FriendshipChange{},
+			Quests:      []QuestChange{},
+		},
+	}
+	if !reflect.DeepEqual(diff.Farmhands, wantFarmhands) {
+		t.Errorf("farmhands = %+v, want %+v", diff.Farmhands, wantFarmhands)
+	}
+
+	for _, line := range []string{
+		"金钱: 500 → 800（+300）",
+		"关系: Abigail 朋友 → 约会中",
+		"[Bob] 金钱: 100 → 250（+150）",
+		"[Bob] 背包: Wood 10 → 30（+20）",
+		"[Bob] 关系: Leah 4 → 5 心",
+		"[Bob] 任务: 接受 Rat Problem",
+		"农场帮手: Erin 加入了农场",
+	} {
+		if !containsLine(diff.Summary, line) {
+			t.Errorf("summary is missing %q: %q", line, diff.Summary)
+		}
+	}
+	for _, line := range diff.Summary {
+		if strings.Contains(line, "Dave") {
+			t.Errorf("summary mentions unchanged farmhand: %q", line)
+		}
+	}
+}
+
+func TestDiffSaveDocuments15(t *testing.T) {
+	diff := diffTestDocuments(t, diffBase15Save, diffTarget15Save)
+
+	if len(diff.Fields) != 0 || len(diff.Inventory) != 0 || len(diff.Friendships) != 0 || len(diff.Quests) != 0 {
+		t.Errorf("host changed: %+v", diff)
+	}
+	wantBuildings := []BuildingChange{{Type: "Log Cabin", Location: "Farm", X: 5, Change: ChangeRemoved}}
+	if !reflect.DeepEqual(diff.Buildings, wantBuildings) {
+		t.Errorf("buildings = %+v, want %+v", diff.Buildings, wantBuildings)
+	}
+
+	if len(diff.Farmhands) != 2 {
+		t.Fatalf("farmhands = %+v, want Carol and Frank", diff.Farmhands)
+	}
+	carol, frank := diff.Farmhands[0], diff.Farmhands[1]
+	if carol.Player != "Carol" || carol.Change != ChangeChanged {
+		t.Errorf("first farmhand = %+v, want Carol changed", carol)
+	}
+	wantInventory := []InventoryChange{{Name: "Copper Ore", Old: 3, New: 8, Delta: 5, Change: ChangeChanged}}
+	if !reflect.DeepEqual(carol.Inventory, wantInventory) {
+		t.Errorf("Carol inventory = %+v, want %+v", carol.Inventory, wantInventory)
+	}
+	if frank.Player != "Frank" || frank.UniqueID != 600 || frank.Change != ChangeRemoved {
+		t.Errorf("second farmhand = %+v, want Frank removed", frank)
+	}
+	if !containsLine(diff.Summary, "农场帮手: Frank 不在存档中了") {
+		t.Errorf("summary is missing Frank: %q", diff.Summary)
+	}
+}
+
+func TestDiffSaveDocumentsMatchesFarmhandsAcrossVersions(t *testing.T) {
+	// 1.5 升级到 1.6 后农场帮手从小屋移到了 farmhands 中，按 UniqueMultiplayerID 仍然是同一个人
+	diff := diffTestDocuments(t, diffTarget15Save, diffCarol16Save)
+	if len(diff.Farmhands) != 0 {
+		t.Errorf("farmhands = %+v, want no changes", diff.Farmhands)
+	}
+}
+
+func TestDiffSaveDocumentsIdentical(t *testing.T) {
+	for _, save := range []string{diffBase16Save, diffBase15Save} {
+		diff := diffTestDocuments(t, save, save)
+		if !diff.Identical {
+			t.Errorf("diff of the same save is not identical: %+v", diff)
+		}
+		if want := []string{"游戏内容没有变化"}; !reflect.DeepEqual(diff.Summary, want) {
+			t.Errorf("summary = %q, want %q", diff.Summary, want)
+		}
+	}
+}
+
+func TestDocumentFarmhands(t *testing.T) {
+	tests := []struct {
+		save string
+		want []string
+	}{
+		{diffBase16Save, []string{"Bob", "Dave"}},
+		{diffTarget16Save, []string{"Dave", "Bob", "Erin"}},
+		{diffBase15Save, []string{"Carol", "Frank"}},
+		{relationships15Save, []string{"Carol"}},
+	}
+	for _, tt := range tests {
+		names := make([]string, 0)
+		for _, farmhand := range documentFarmhands(parseTestDocument(t, tt.save)) {
+			names = append(names, farmhand.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("documentFarmhands() = %v, want %v", names, tt.want)
+		}
+	}
+}
@@ -1,80 +1,119 @@
 package main
 
 import (
-	"archive/zip"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SaveService 存档服务
 type SaveService struct {
-	currentPath string
-	recentPaths []string
-	logs        []OperationLog
+	mu             sync.RWMutex
+	libraries      map[string]SaveLibrary
+	defaultLibrary string
+	recentPaths    []string
+	logs           []OperationLog
+
+	// 配置文件中的候选存档路径和允许访问的根目录
+	configCandidates []string
+	configRoots      []string
+
+	// 配置文件中的导入收件箱目录和自动导入间隔（秒）
+	configInbox         string
+	configInboxInterval int
+
+	// 配置文件中的导出压缩级别和确定性压缩包开关
+	configZipCompression   string
+	configZipDeterministic bool
+
+	// 配置文件中的备份复制目标
+	configBackupTargets []BackupTargetConfig
+
+	// 备份复制状态，catalogMu 保护备份目录中的状态文件
+	catalogMu sync.Mutex
+
+	// 配置文件中的自动历史快照开关，historyMu 保证同一时间只有一个操作修改历史仓库
+	configHistoryEnabled bool
+	historyMu            sync.Mutex
+
+	// 分块上传会话
+	uploadMu sync.Mutex
+	uploads  map[string]*UploadSession
+
+	// 后台任务
+	jobs *JobManager
 }
 
 // NewSaveService 创建新的存档服务实例
 func NewSaveService() *SaveService {
-	// 默认路径指向项目同级目录的 stardew-multiplayer-docker/valley_saves
-	defaultPath := "../stardew-multiplayer-docker/valley_saves"
-
-	// 尝试多个可能的路径
-	possiblePaths := []string{
-		defaultPath, // 主要目标路径
-		"../stardew-multiplayer-docker/valley_saves", // Windows风格路径
-		"../valley_saves", // 备用路径1
-		"./valley_saves",  // 备用路径2
-	}
-
-	// 找到第一个有效的路径
-	var validPath string
-	for _, path := range possiblePaths {
-		if cleanPath := filepath.Clean(path); cleanPath != "" {
-			if info, err := os.Stat(cleanPath); err == nil && info.IsDir() {
-				validPath = cleanPath
-				break
-			}
-			// 如果目录不存在，尝试创建它（仅对默认路径）
-			if path == defaultPath {
-				if err := os.MkdirAll(cleanPath, 0755); err == nil {
-					validPath = cleanPath
-					break
-				}
-			}
+	s := &SaveService{
+		libraries:      make(map[string]SaveLibrary),
+		defaultLibrary: defaultLibraryName,
+		recentPaths:    make([]string, 0),
+		logs:           make([]OperationLog, 0),
+		uploads:        make(map[string]*UploadSession),
+		jobs:           NewJobManager(jobWorkerCount()),
+	}
+
+	// 加载上次保存的路径配置
+	if err := s.loadConfig(); err != nil {
+		log.Printf("加载路径配置失败: %v", err)
+	}
+
+	// 只有默认存档库缺失或其路径失效时才重新检测
+	lib, ok := s.libraries[s.defaultLibrary]
+	if !ok || !s.isValidPath(lib.Path) {
+		if ok {
+			log.Printf("存档库 %s 的路径已失效: %s，重新检测存档路径", lib.Name, lib.Path)
 		}
+		validPath := s.detectSavePath()
+		lib.Name = s.defaultLibrary
+		lib.Path = validPath
+		s.libraries[s.defaultLibrary] = lib
+		s.addToRecentPaths(validPath)
 	}
 
-	// 如果没有找到有效路径，使用默认路径并尝试创建
-	if validPath == "" {
-		validPath = defaultPath
-		os.MkdirAll(validPath, 0755)
+	// 检测到的路径或其他存档库可能不在允许的根目录内
+	s.validateLibraries()
+
+	// 清理上次异常退出遗留的暂存目录
+	for _, lib := range s.libraries {
+		cleanupStagingDirs(lib.Path)
 	}
 
 	// 确保其他必要目录存在
-	os.MkdirAll("./downloads", 0755)
 	os.MkdirAll("./backups", 0755)
+	os.MkdirAll(s.inboxDir(), 0755)
+	os.MkdirAll(uploadsDir, 0755)
 
-	return &SaveService{
-		currentPath: validPath,
-		recentPaths: []string{validPath},
-		logs:        make([]OperationLog, 0),
-	}
+	// 恢复服务重启前未完成的分块上传
+	s.loadUploadSessions()
+
+	return s
 }
 
 // GetCurrentPath 获取当前存档路径
 func (s *SaveService) GetCurrentPath(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
 	config := PathConfig{
-		CurrentPath: s.currentPath,
-		RecentPaths: s.recentPaths,
-		IsValid:     s.isValidPath(s.currentPath),
+		Library:     lib.Name,
+		CurrentPath: lib.Path,
+		RecentPaths: s.getRecentPaths(),
+		IsValid:     s.isValidPath(lib.Path),
 	}
 
 	if !config.IsValid {
@@ -107,17 +146,30 @@ func (s *SaveService) SetPath(c *gin.Context) {
 		return
 	}
 
-	s.currentPath = req.Path
-	s.addToRecentPaths(req.Path)
+	if req.Library == "" {
+		req.Library = c.Query("library")
+	}
+	lib, ok := s.getLibrary(req.Library)
+	if !ok {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("存档库不存在: %s", req.Library),
+		})
+		return
+	}
+
+	s.setLibraryPath(lib.Name, req.Path)
+	s.persistConfig()
 
-	s.addLog("path_change", fmt.Sprintf("切换存档路径到: %s", req.Path), true, "")
+	s.addLog("path_change", fmt.Sprintf("切换存档库 %s 的路径到: %s", lib.Name, req.Path), true, "")
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Message: "路径设置成功",
 		Data: PathConfig{
-			CurrentPath: s.currentPath,
-			RecentPaths: s.recentPaths,
+			Library:     lib.Name,
+			CurrentPath: req.Path,
+			RecentPaths: s.getRecentPaths(),
 			IsValid:     true,
 		},
 	})
@@ -145,54 +197,36 @@ func (s *SaveService) ValidatePath(c *gin.Context) {
 
 // GetPathInfo 获取路径信息和检测状态
 func (s *SaveService) GetPathInfo(c *gin.Context) {
-	// 检测所有可能的路径
-	possiblePaths := []string{
-		"../stardew-multiplayer-docker/valley_saves",
-		"../valley_saves",
-		"./valley_saves",
-	}
-
-	pathStatus := make([]gin.H, 0)
-	for i, path := range possiblePaths {
-		cleanPath := filepath.Clean(path)
-		exists := false
-		isDir := false
-		var err string
-
-		if info, statErr := os.Stat(cleanPath); statErr == nil {
-			exists = true
-			isDir = info.IsDir()
-		} else {
-			err = statErr.Error()
-		}
-
-		status := gin.H{
-			"path":     path,
-			"priority": i + 1,
-			"exists":   exists,
-			"isDir":    isDir,
-			"current":  cleanPath == s.currentPath,
-		}
-
-		if err != "" {
-			status["error"] = err
-		}
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
 
-		pathStatus = append(pathStatus, status)
+	// 检测所有候选路径
+	pathStatus := s.detectPaths()
+	for i := range pathStatus {
+		pathStatus[i].Current = pathStatus[i].Path == filepath.Clean(lib.Path)
 	}
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Data: gin.H{
-			"currentPath": s.currentPath,
-			"pathStatus":  pathStatus,
+			"library":      lib.Name,
+			"currentPath":  lib.Path,
+			"pathStatus":   pathStatus,
+			"allowedRoots": s.allowedRoots(),
 		},
 	})
 }
 
 // GetSaves 获取存档列表
 func (s *SaveService) GetSaves(c *gin.Context) {
-	saves, err := s.scanSaves()
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	saves, err := s.scanSaves(lib.Path)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, APIResponse{
 			Success: false,
@@ -214,8 +248,13 @@ func (s *SaveService) GetSaves(c *gin.Context) {
 
 // GetSaveDetails 获取存档详细信息
 func (s *SaveService) GetSaveDetails(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
-	save, err := s.getSaveByID(id)
+	save, err := s.getSaveByID(lib.Path, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, APIResponse{
 			Success: false,
@@ -224,6 +263,13 @@ func (s *SaveService) GetSaveDetails(c *gin.Context) {
 		return
 	}
 
+	// 详情中附带关系视图，存档无法解析时只返回摘要
+	if save.IsValid {
+		if doc, err := s.loadSaveDocument(save.Path); err == nil {
+			save.Relationships = saveRelationships(doc)
+		}
+	}
+
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Data:    save,
@@ -232,215 +278,254 @@ func (s *SaveService) GetSaveDetails(c *gin.Context) {
 
 // DeleteSave 删除存档
 func (s *SaveService) DeleteSave(c *gin.Context) {
-	id := c.Param("id")
-	save, err := s.getSaveByID(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, APIResponse{
-			Success: false,
-			Error:   "存档不存在",
-		})
+	lib, ok := s.requestLibrary(c)
+	if !ok {
 		return
 	}
 
-	// 备份存档
-	backupPath := filepath.Join("./backups", fmt.Sprintf("%s_%d.zip", save.Name, time.Now().Unix()))
-	if err := s.createBackup(save.Path, backupPath); err != nil {
-		s.addLog("delete", fmt.Sprintf("删除存档前备份失败: %s", save.Name), false, err.Error())
-	}
+	// 与批量删除相同：先检查路径再备份，备份失败时不删除，除非 force=true
+	item := s.deleteWithBackup(lib.Path, c.Param("id"), c.Query("force") == "true")
+	s.logBatchResult("delete", "删除存档", &BatchResult{Results: []BatchItemResult{item}})
 
-	// 删除存档目录
-	if err := os.RemoveAll(save.Path); err != nil {
-		s.addLog("delete", fmt.Sprintf("删除存档失败: %s", save.Name), false, err.Error())
-		c.JSON(http.StatusInternalServerError, APIResponse{
+	switch item.Status {
+	case BatchStatusDeleted:
+		c.JSON(http.StatusOK, APIResponse{
+			Success: true,
+			Message: "存档删除成功",
+			Data:    item,
+		})
+	case BatchStatusNotFound:
+		c.JSON(http.StatusNotFound, APIResponse{
 			Success: false,
-			Error:   "删除存档失败: " + err.Error(),
+			Error:   item.Error,
 		})
-		return
-	}
-
-	s.addLog("delete", fmt.Sprintf("删除存档: %s", save.Name), true, "")
-
-	c.JSON(http.StatusOK, APIResponse{
-		Success: true,
-		Message: "存档删除成功",
-	})
-}
-
-// ImportSave 导入存档
-func (s *SaveService) ImportSave(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
+	default:
 		c.JSON(http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "文件上传失败",
+			Error:   item.Error,
+			Data:    item,
 		})
-		return
 	}
+}
 
-	// 验证文件类型
-	if !strings.HasSuffix(strings.ToLower(file.Filename), ".zip") {
+// RenameSave 重命名存档
+func (s *SaveService) RenameSave(c *gin.Context) {
+	var req RenameSaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "只支持ZIP格式的存档文件",
+			Error:   "请求参数无效",
 		})
 		return
 	}
 
-	// 文件大小限制 (100MB)
-	if file.Size > 100*1024*1024 {
+	if !isValidSaveName(req.NewName) {
 		c.JSON(http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "文件大小超过限制(100MB)",
+			Error:   "存档名称无效",
 		})
 		return
 	}
 
-	// 解析请求参数
-	overwrite := c.DefaultPostForm("overwriteExisting", "false") == "true"
-	backup := c.DefaultPostForm("backupExisting", "true") == "true"
-
-	// 保存上传的文件
-	tempPath := filepath.Join("./temp", file.Filename)
-	os.MkdirAll("./temp", 0755)
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
 
-	if err := c.SaveUploadedFile(file, tempPath); err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
+	id := c.Param("id")
+	save, err := s.getSaveByID(lib.Path, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
 			Success: false,
-			Error:   "保存文件失败",
+			Error:   "存档不存在",
 		})
 		return
 	}
-	defer os.Remove(tempPath)
 
-	// 解压并导入
-	result, err := s.extractAndImportSave(tempPath, overwrite, backup)
+	newPath, err := s.renameSaveDirectory(save.Path, req.NewName, req.FarmName)
 	if err != nil {
-		s.addLog("import", fmt.Sprintf("导入存档失败: %s", file.Filename), false, err.Error())
-		c.JSON(http.StatusInternalServerError, APIResponse{
+		s.addLog("rename", fmt.Sprintf("重命名存档失败: %s -> %s", save.Name, req.NewName), false, err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSaveExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, APIResponse{
 			Success: false,
-			Error:   "导入存档失败: " + err.Error(),
+			Error:   "重命名存档失败: " + err.Error(),
 		})
 		return
 	}
 
-	s.addLog("import", fmt.Sprintf("导入存档: %s", file.Filename), true, "")
+	s.addLog("rename", fmt.Sprintf("重命名存档: %s -> %s", save.Name, req.NewName), true, "")
 
+	renamed := s.parseSaveDirectory(lib.Path, newPath)
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
-		Message: "存档导入成功",
-		Data:    result,
+		Message: "存档重命名成功",
+		Data: gin.H{
+			"id":    renamed.ID,
+			"oldId": id,
+			"save":  renamed,
+		},
 	})
 }
 
-// ExportSave 导出存档
-func (s *SaveService) ExportSave(c *gin.Context) {
-	id := c.Param("id")
-	save, err := s.getSaveByID(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, APIResponse{
-			Success: false,
-			Error:   "存档不存在",
-		})
+// ImportSave 导入存档
+func (s *SaveService) ImportSave(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
 		return
 	}
 
-	// 创建ZIP文件
-	filename := fmt.Sprintf("%s_%s.zip", save.Name, time.Now().Format("20060102_150405"))
-	zipPath := filepath.Join("./downloads", filename)
+	tempPath, filename, cleanup, ok := s.receiveUpload(c)
+	if !ok {
+		return
+	}
 
-	if err := s.createZipFromDirectory(save.Path, zipPath); err != nil {
-		s.addLog("export", fmt.Sprintf("导出存档失败: %s", save.Name), false, err.Error())
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Success: false,
-			Error:   "导出存档失败: " + err.Error(),
+	// 解析请求参数
+	req := ImportRequest{
+		OverwriteExisting: c.DefaultPostForm("overwriteExisting", "false") == "true",
+		BackupExisting:    c.DefaultPostForm("backupExisting", "true") == "true",
+		AllowInvalid:      c.DefaultPostForm("allowInvalid", "false") == "true",
+	}
+	for _, name := range strings.Split(c.PostForm("overwriteSaves"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.OverwriteSaves = append(req.OverwriteSaves, name)
+		}
+	}
+
+	// async=true 时在后台任务中解压导入，上传的临时文件由任务负责清理
+	if wantsAsync(c) {
+		submitted := s.submitJob(c, "import", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+			defer cleanup()
+			return s.runImportJob(filename, func() (*ImportResult, error) {
+				return s.extractAndImportSave(ctx, lib.Path, tempPath, req, job)
+			})
 		})
+		if !submitted {
+			cleanup()
+		}
 		return
 	}
+	defer cleanup()
 
-	s.addLog("export", fmt.Sprintf("导出存档: %s", save.Name), true, "")
+	// 解压并导入
+	result, err := s.extractAndImportSave(context.Background(), lib.Path, tempPath, req, noProgress{})
+	if err != nil {
+		s.addLog("import", fmt.Sprintf("导入存档失败: %s", filename), false, err.Error())
+		s.respondImportError(c, err)
+		return
+	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.File(zipPath)
+	s.logImportResult(result, filename)
+	s.respondImportResult(c, result)
+}
 
-	// 清理临时文件
-	go func() {
-		time.Sleep(5 * time.Minute)
-		os.Remove(zipPath)
-	}()
+// runImportJob 执行导入任务并记录日志，没有任何存档导入成功时任务失败，但仍保留每个存档的结果
+func (s *SaveService) runImportJob(source string, run func() (*ImportResult, error)) (interface{}, error) {
+	result, err := run()
+	if err != nil {
+		s.addLog("import", fmt.Sprintf("导入存档失败: %s", source), false, err.Error())
+		if result != nil {
+			s.logImportResult(result, source)
+			return result, err
+		}
+		return nil, err
+	}
+
+	s.logImportResult(result, source)
+	if result.Imported == 0 {
+		return result, fmt.Errorf("没有导入任何存档: %s", result.Results[0].Error)
+	}
+	return result, nil
 }
 
-// BatchExport 批量导出存档
-func (s *SaveService) BatchExport(c *gin.Context) {
-	var req BatchRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Success: false,
-			Error:   "请求参数无效",
-		})
-		return
+// logImportResult 为导入结果中的每个存档单独记录日志，source 为上传文件名或本地路径
+func (s *SaveService) logImportResult(result *ImportResult, source string) {
+	for _, item := range result.Results {
+		if item.Status == ImportStatusImported {
+			s.addLog("import", fmt.Sprintf("导入存档: %s (%s)", item.Name, source), true, "")
+		} else {
+			s.addLog("import", fmt.Sprintf("导入存档失败: %s (%s)", item.Name, source), false, item.Error)
+		}
 	}
+}
 
-	if len(req.SaveIDs) == 0 {
+// respondImportResult 返回导入结果，没有任何存档导入成功时返回 400
+func (s *SaveService) respondImportResult(c *gin.Context, result *ImportResult) {
+	if result.Imported == 0 {
 		c.JSON(http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "请选择要导出的存档",
+			Error:   "没有导入任何存档: " + result.Results[0].Error,
+			Data:    result,
 		})
 		return
 	}
 
-	// 创建批量导出ZIP
-	filename := fmt.Sprintf("stardew_saves_batch_%s.zip", time.Now().Format("20060102_150405"))
-	zipPath := filepath.Join("./downloads", filename)
+	message := "存档导入成功"
+	if result.Failed > 0 {
+		message = fmt.Sprintf("导入 %d 个存档，%d 个失败", result.Imported, result.Failed)
+	} else if result.Imported > 1 {
+		message = fmt.Sprintf("成功导入 %d 个存档", result.Imported)
+	}
 
-	zipFile, err := os.Create(zipPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Success: false,
-			Error:   "创建导出文件失败",
-		})
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
+
+// PreviewImport 预览导入：校验上传的压缩包并解析存档摘要，不修改任何文件
+func (s *SaveService) PreviewImport(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
 		return
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
 
-	successCount := 0
-	for _, id := range req.SaveIDs {
-		save, err := s.getSaveByID(id)
-		if err != nil {
-			continue
-		}
+	tempPath, _, cleanup, ok := s.receiveUpload(c)
+	if !ok {
+		return
+	}
+	defer cleanup()
 
-		if err := s.addDirectoryToZip(zipWriter, save.Path, save.Name); err != nil {
-			continue
-		}
-		successCount++
+	preview, err := s.previewZipImport(lib.Path, tempPath)
+	if err != nil {
+		s.respondImportError(c, err)
+		return
 	}
 
-	if successCount == 0 {
-		os.Remove(zipPath)
-		c.JSON(http.StatusInternalServerError, APIResponse{
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    preview,
+	})
+}
+
+// respondImportError 根据导入错误类型返回对应的响应
+func (s *SaveService) respondImportError(c *gin.Context, err error) {
+	var validationErr *ArchiveValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, APIResponse{
 			Success: false,
-			Error:   "没有成功导出任何存档",
+			Error:   "存档文件校验失败: " + err.Error(),
+			Data:    gin.H{"invalidEntries": validationErr.Issues},
 		})
 		return
 	}
 
-	s.addLog("batch_export", fmt.Sprintf("批量导出 %d 个存档", successCount), true, "")
-
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.File(zipPath)
-
-	// 清理临时文件
-	go func() {
-		time.Sleep(5 * time.Minute)
-		os.Remove(zipPath)
-	}()
+	c.JSON(http.StatusInternalServerError, APIResponse{
+		Success: false,
+		Error:   "导入存档失败: " + err.Error(),
+	})
 }
 
 // BatchDelete 批量删除存档
 func (s *SaveService) BatchDelete(c *gin.Context) {
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
 	var req BatchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, APIResponse{
@@ -458,38 +543,19 @@ func (s *SaveService) BatchDelete(c *gin.Context) {
 		return
 	}
 
-	successCount := 0
-	for _, id := range req.SaveIDs {
-		save, err := s.getSaveByID(id)
-		if err != nil {
-			continue
-		}
-
-		// 备份存档
-		backupPath := filepath.Join("./backups", fmt.Sprintf("%s_%d.zip", save.Name, time.Now().Unix()))
-		s.createBackup(save.Path, backupPath)
-
-		// 删除存档
-		if err := os.RemoveAll(save.Path); err == nil {
-			successCount++
-		}
+	if wantsAsync(c) {
+		s.submitJob(c, "batch_delete", func(ctx context.Context, job *jobEntry) (interface{}, error) {
+			result, err := s.batchDelete(ctx, lib.Path, req.SaveIDs, req.Force, job)
+			if err == nil && result.Succeeded == 0 {
+				err = fmt.Errorf("没有删除任何存档: %s", result.Results[0].Error)
+			}
+			return result, err
+		})
+		return
 	}
 
-	s.addLog("batch_delete", fmt.Sprintf("批量删除 %d 个存档", successCount), true, "")
-
-	c.JSON(http.StatusOK, APIResponse{
-		Success: true,
-		Message: fmt.Sprintf("成功删除 %d 个存档", successCount),
-	})
-}
-
-// CompareSaves 比较存档
-func (s *SaveService) CompareSaves(c *gin.Context) {
-	// 这个功能在导入时检测冲突时会调用
-	c.JSON(http.StatusOK, APIResponse{
-		Success: true,
-		Message: "存档比较功能",
-	})
+	result, _ := s.batchDelete(context.Background(), lib.Path, req.SaveIDs, req.Force, noProgress{})
+	s.respondBatchResult(c, result, "删除")
 }
 
 // ResolveConflict 解决冲突
@@ -523,11 +589,13 @@ func (s *SaveService) GetLogs(c *gin.Context) {
 	start := (page - 1) * pageSize
 	end := start + pageSize
 
-	total := len(s.logs)
-
 	// 反向排序（最新的在前面）
+	s.mu.RLock()
 	sortedLogs := make([]OperationLog, len(s.logs))
 	copy(sortedLogs, s.logs)
+	s.mu.RUnlock()
+
+	total := len(sortedLogs)
 	sort.Slice(sortedLogs, func(i, j int) bool {
 		return sortedLogs[i].Timestamp.After(sortedLogs[j].Timestamp)
 	})
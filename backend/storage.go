@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupStorage 备份文件的存储位置。name 是不含目录的备份文件名
+type BackupStorage interface {
+	// Describe 返回存储位置的概要
+	Describe() BackupTargetInfo
+	// Put 写入备份，写入完成前同名备份不会被列出或读取到不完整的内容
+	Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error
+	// Open 读取备份
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// List 列出所有备份
+	List(ctx context.Context) ([]StoredBackup, error)
+	// Delete 删除备份
+	Delete(ctx context.Context, name string) error
+}
+
+// StoredBackup 存储中的一个备份文件
+type StoredBackup struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// errBackupNotFound 存储中没有该备份
+var errBackupNotFound = fmt.Errorf("备份不存在")
+
+// localStorage 本地目录
+type localStorage struct {
+	name string
+	dir  string
+}
+
+func newLocalStorage(name, dir string) *localStorage {
+	return &localStorage{name: name, dir: filepath.Clean(dir)}
+}
+
+func (l *localStorage) Describe() BackupTargetInfo {
+	return BackupTargetInfo{Name: l.name, Type: "local", Location: l.dir}
+}
+
+// Put 先写入 .partial 临时文件，完成后再重命名
+func (l *localStorage) Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+
+	targetPath := filepath.Join(l.dir, name)
+	partialPath := targetPath + ".partial"
+	file, err := os.Create(partialPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, &contextReader{ctx: ctx, r: r})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(partialPath, targetPath)
+	}
+	if err != nil {
+		os.Remove(partialPath)
+	}
+	return err
+}
+
+func (l *localStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(l.dir, name))
+	if os.IsNotExist(err) {
+		return nil, errBackupNotFound
+	}
+	return file, err
+}
+
+func (l *localStorage) List(ctx context.Context) ([]StoredBackup, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []StoredBackup
+	for _, entry := range entries {
+		if entry.IsDir() || !isBackupFileName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, StoredBackup{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return backups, nil
+}
+
+func (l *localStorage) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(l.dir, name))
+	if os.IsNotExist(err) {
+		return errBackupNotFound
+	}
+	return err
+}
+
+// contextReader 在 ctx 取消后停止读取
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// backupTargetConfigs 返回备份复制目标：环境变量 SAVE_BACKUP_TARGETS（JSON 数组）> 配置文件 backupTargets
+func (s *SaveService) backupTargetConfigs() []BackupTargetConfig {
+	if value := os.Getenv("SAVE_BACKUP_TARGETS"); value != "" {
+		var targets []BackupTargetConfig
+		if err := json.Unmarshal([]byte(value), &targets); err == nil {
+			return targets
+		}
+		log.Printf("无效的 SAVE_BACKUP_TARGETS: 不是有效的 JSON 数组")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]BackupTargetConfig(nil), s.configBackupTargets...)
+}
+
+// backupTargets 创建所有复制目标，配置无效的目标只记录日志并跳过
+func (s *SaveService) backupTargets() []BackupStorage {
+	var targets []BackupStorage
+	seen := make(map[string]bool)
+	for _, config := range s.backupTargetConfigs() {
+		if !isValidSaveName(config.Name) || seen[config.Name] {
+			log.Printf("跳过备份复制目标: 名称无效或重复: %q", config.Name)
+			continue
+		}
+		storage, err := newBackupStorage(config)
+		if err != nil {
+			log.Printf("跳过备份复制目标 %s: %v", config.Name, err)
+			continue
+		}
+		seen[config.Name] = true
+		targets = append(targets, storage)
+	}
+	return targets
+}
+
+// backupTarget 按名称查找复制目标
+func (s *SaveService) backupTarget(name string) (BackupStorage, bool) {
+	for _, target := range s.backupTargets() {
+		if target.Describe().Name == name {
+			return target, true
+		}
+	}
+	return nil, false
+}
+
+// newBackupStorage 按配置创建存储
+func newBackupStorage(config BackupTargetConfig) (BackupStorage, error) {
+	config.AccessKey = os.ExpandEnv(config.AccessKey)
+	config.SecretKey = os.ExpandEnv(config.SecretKey)
+	config.Password = os.ExpandEnv(config.Password)
+
+	switch strings.ToLower(config.Type) {
+	case "local":
+		if config.Path == "" {
+			return nil, fmt.Errorf("缺少 path")
+		}
+		return newLocalStorage(config.Name, config.Path), nil
+	case "s3":
+		return newS3Storage(config, nil)
+	case "sftp":
+		return newSFTPStorage(config)
+	}
+	return nil, fmt.Errorf("不支持的类型: %q", config.Type)
+}
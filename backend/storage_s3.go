@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Storage S3 兼容的对象存储，签名、重试和分片上传由 minio-go 处理
+type s3Storage struct {
+	name     string
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	client   *minio.Client
+}
+
+// newS3Storage 创建 S3 存储，transport 为 nil 时使用默认的 HTTP 传输
+func newS3Storage(config BackupTargetConfig, transport http.RoundTripper) (*s3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("缺少 endpoint 或 bucket")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("缺少 accessKey 或 secretKey")
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("无效的 endpoint: %s", config.Endpoint)
+	}
+	if strings.Trim(endpoint.Path, "/") != "" {
+		return nil, fmt.Errorf("endpoint 不能包含路径: %s", config.Endpoint)
+	}
+
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	prefix := strings.Trim(config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	lookup := minio.BucketLookupPath
+	if config.VirtualHost {
+		lookup = minio.BucketLookupDNS
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Region:       region,
+		BucketLookup: lookup,
+		Transport:    transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("无效的 endpoint: %v", err)
+	}
+
+	return &s3Storage{
+		name:     config.Name,
+		endpoint: endpoint,
+		bucket:   config.Bucket,
+		prefix:   prefix,
+		client:   client,
+	}, nil
+}
+
+func (s *s3Storage) Describe() BackupTargetInfo {
+	return BackupTargetInfo{
+		Name:     s.name,
+		Type:     "s3",
+		Location: fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.endpoint.String(), "/"), s.bucket, s.prefix),
+	}
+}
+
+// Put 上传备份，对象存储保证上传完成前对象不可见
+func (s *s3Storage) Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+name, r, size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return s3Error(err)
+}
+
+// Open 先读取对象信息，对象不存在时立即返回 errBackupNotFound
+func (s *s3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, s3Error(err)
+	}
+	return object, nil
+}
+
+// List 列出前缀下的备份，不包含更深层级的对象
+func (s *s3Storage) List(ctx context.Context) ([]StoredBackup, error) {
+	var backups []StoredBackup
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix}) {
+		if object.Err != nil {
+			return nil, s3Error(object.Err)
+		}
+		name := strings.TrimPrefix(object.Key, s.prefix)
+		if strings.Contains(name, "/") || !isBackupFileName(name) {
+			continue
+		}
+		backups = append(backups, StoredBackup{Name: name, Size: object.Size, ModTime: object.LastModified})
+	}
+	return backups, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, name string) error {
+	return s3Error(s.client.RemoveObject(ctx, s.bucket, s.prefix+name, minio.RemoveObjectOptions{}))
+}
+
+// s3Error 把对象不存在转换成 errBackupNotFound，其他错误带上 S3 的错误代码
+func s3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) || resp.Code == "" {
+		return err
+	}
+	if resp.Code == "NoSuchKey" {
+		return errBackupNotFound
+	}
+	return fmt.Errorf("%s: %s", resp.Code, resp.Message)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpStorage 通过 SFTP 保存到远程目录，SFTP 协议由 github.com/pkg/sftp 实现
+type sftpStorage struct {
+	name   string
+	addr   string
+	dir    string
+	config *ssh.ClientConfig
+}
+
+func newSFTPStorage(config BackupTargetConfig) (*sftpStorage, error) {
+	if config.Host == "" || config.User == "" || config.Path == "" {
+		return nil, fmt.Errorf("缺少 host、user 或 path")
+	}
+
+	var auth []ssh.AuthMethod
+	if config.PrivateKeyFile != "" {
+		data, err := os.ReadFile(config.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取私钥失败: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析私钥失败: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("缺少 password 或 privateKeyFile")
+	}
+
+	// 必须校验服务器公钥，除非明确关闭
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case config.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(config.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("解析 hostKey 失败: %v", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(key)
+	case config.KnownHostsFile != "":
+		callback, err := knownhosts.New(config.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 knownHostsFile 失败: %v", err)
+		}
+		hostKeyCallback = callback
+	case config.InsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("缺少 hostKey 或 knownHostsFile")
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 22
+	}
+
+	return &sftpStorage{
+		name: config.Name,
+		addr: net.JoinHostPort(config.Host, strconv.Itoa(port)),
+		dir:  path.Clean(config.Path),
+		config: &ssh.ClientConfig{
+			User:            config.User,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         30 * time.Second,
+		},
+	}, nil
+}
+
+func (s *sftpStorage) Describe() BackupTargetInfo {
+	return BackupTargetInfo{
+		Name:     s.name,
+		Type:     "sftp",
+		Location: fmt.Sprintf("sftp://%s@%s%s", s.config.User, s.addr, s.dir),
+	}
+}
+
+// sftpConn 一次操作使用的 SSH 连接和 SFTP 客户端，ctx 取消时断开连接
+type sftpConn struct {
+	*sftp.Client
+	ssh *ssh.Client
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (c *sftpConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.Client.Close()
+		err = c.ssh.Close()
+	})
+	return err
+}
+
+// connect 建立 SSH 连接并打开 sftp 子系统，ctx 取消时断开连接
+func (s *sftpStorage) connect(ctx context.Context) (*sftpConn, error) {
+	dialer := net.Dialer{Timeout: s.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, s.addr, s.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+
+	c := &sftpConn{Client: client, ssh: sshClient, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.done:
+		}
+	}()
+	return c, nil
+}
+
+// Put 先上传到 .partial 临时文件，完成后再重命名
+func (s *sftpStorage) Put(ctx context.Context, name string, r io.ReadSeeker, size int64) error {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.MkdirAll(s.dir); err != nil {
+		return err
+	}
+
+	targetPath := path.Join(s.dir, name)
+	partialPath := targetPath + ".partial"
+	file, err := client.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	_, err = file.ReadFrom(&contextReader{ctx: ctx, r: r})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = client.replace(partialPath, targetPath)
+	}
+	if err != nil {
+		client.Remove(partialPath)
+	}
+	return err
+}
+
+func (s *sftpStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, err := client.Open(path.Join(s.dir, name))
+	if err != nil {
+		client.Close()
+		return nil, sftpError(err)
+	}
+	return &sftpFileReader{File: file, conn: client}, nil
+}
+
+func (s *sftpStorage) List(ctx context.Context) ([]StoredBackup, error) {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	entries, err := client.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []StoredBackup
+	for _, entry := range entries {
+		if entry.IsDir() || !isBackupFileName(entry.Name()) {
+			continue
+		}
+		backups = append(backups, StoredBackup{Name: entry.Name(), Size: entry.Size(), ModTime: entry.ModTime()})
+	}
+	return backups, nil
+}
+
+func (s *sftpStorage) Delete(ctx context.Context, name string) error {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return sftpError(client.Remove(path.Join(s.dir, name)))
+}
+
+// sftpError 把文件不存在转换成 errBackupNotFound
+func sftpError(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return errBackupNotFound
+	}
+	return err
+}
+
+// sftpFileReader 读取远程文件，关闭时断开连接
+type sftpFileReader struct {
+	*sftp.File
+	conn *sftpConn
+}
+
+func (f *sftpFileReader) Close() error {
+	f.File.Close()
+	return f.conn.Close()
+}
+
+// posixRenameExtension OpenSSH 的扩展，重命名时原子地覆盖已有文件
+const posixRenameExtension = "posix-rename@openssh.com"
+
+// replace 把 oldPath 重命名为 newPath，newPath 已存在时覆盖它。
+// SFTP v3 的重命名不会覆盖已有文件，服务器不支持或拒绝 posix-rename 时先把已有文件移到一边，
+// 替换成功后再删除，替换失败时恢复，任何时候 newPath 要么是旧文件要么是新文件
+func (c *sftpConn) replace(oldPath, newPath string) error {
+	if _, ok := c.HasExtension(posixRenameExtension); ok {
+		err := c.PosixRename(oldPath, newPath)
+		var status *sftp.StatusError
+		if !errors.As(err, &status) || status.FxCode() != sftp.ErrSSHFxOpUnsupported {
+			return err
+		}
+	}
+
+	err := c.Rename(oldPath, newPath)
+	if err == nil {
+		return nil
+	}
+
+	asidePath := newPath + ".old"
+	c.Remove(asidePath)
+	if asideErr := c.Rename(newPath, asidePath); asideErr != nil {
+		// 目标不存在，说明重命名失败另有原因
+		if errors.Is(asideErr, os.ErrNotExist) {
+			return err
+		}
+		return asideErr
+	}
+	if err := c.Rename(oldPath, newPath); err != nil {
+		c.Rename(asidePath, newPath)
+		return err
+	}
+	c.Remove(asidePath)
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSFTPServer 进程内的 SSH 服务器，sftp 子系统使用 pkg/sftp 的内存文件系统。
+// 重命名遵循 SFTP v3：目标已存在时失败；posixRename 为 false 时拒绝 posix-rename@openssh.com
+type fakeSFTPServer struct {
+	files       sftp.Handlers
+	posixRename bool
+	hostKey     ssh.Signer
+
+	mu         sync.Mutex
+	failRename map[string]bool // 重命名到这些路径时失败一次
+}
+
+func startFakeSFTPServer(t *testing.T, posixRename bool) (*fakeSFTPServer, BackupTargetConfig) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSFTPServer{files: sftp.InMemHandler(), posixRename: posixRename, hostKey: signer, failRename: make(map[string]bool)}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serveConn(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return server, BackupTargetConfig{
+		Name:     "sftp",
+		Type:     "sftp",
+		Host:     host,
+		Port:     portNumber,
+		User:     "u",
+		Password: "pw",
+		Path:     "/backups/stardew",
+		HostKey:  string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+	}
+}
+
+func (f *fakeSFTPServer) serveConn(conn net.Conn) {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "u" && string(password) == "pw" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(f.hostKey)
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						handlers := f.files
+						handlers.FileCmd = &fakeSFTPCmder{server: f, FileCmder: f.files.FileCmd}
+						sftp.NewRequestServer(channel, handlers).Serve()
+						channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// fakeSFTPCmder 在内存文件系统的命令处理上注入重命名失败
+type fakeSFTPCmder struct {
+	sftp.FileCmder
+	server *fakeSFTPServer
+}
+
+// Filecmd 重命名到已存在的文件时照常失败，不消耗注入的失败
+func (c *fakeSFTPCmder) Filecmd(r *sftp.Request) error {
+	if r.Method == "Rename" && !c.server.exists(r.Target) && c.server.takeRenameFailure(r.Target) {
+		return errors.New("injected failure")
+	}
+	return c.FileCmder.Filecmd(r)
+}
+
+func (c *fakeSFTPCmder) PosixRename(r *sftp.Request) error {
+	if !c.server.posixRename {
+		return sftp.ErrSSHFxOpUnsupported
+	}
+	if c.server.takeRenameFailure(r.Target) {
+		return errors.New("injected failure")
+	}
+	return c.FileCmder.(sftp.PosixRenameFileCmder).PosixRename(r)
+}
+
+func (f *fakeSFTPServer) exists(target string) bool {
+	_, err := f.files.FileList.Filelist(sftp.NewRequest("Stat", target))
+	return err == nil
+}
+
+func (f *fakeSFTPServer) takeRenameFailure(target string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fail := f.failRename[target]
+	delete(f.failRename, target)
+	return fail
+}
+
+// remoteNames 列出远程目录中的所有文件
+func remoteNames(t *testing.T, storage *sftpStorage) []string {
+	t.Helper()
+	client, err := storage.connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	entries, err := client.ReadDir(storage.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func newFakeSFTPStorage(t *testing.T, posixRename bool) (*fakeSFTPServer, *sftpStorage) {
+	t.Helper()
+	server, config := startFakeSFTPServer(t, posixRename)
+	storage, err := newSFTPStorage(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, storage
+}
+
+func TestSFTPStorage(t *testing.T) {
+	for _, posixRename := range []bool{true, false} {
+		t.Run("posixRename="+strconv.FormatBool(posixRename), func(t *testing.T) {
+			_, storage := newFakeSFTPStorage(t, posixRename)
+			testBackupStorageRoundTrip(t, storage)
+
+			// 覆盖时不留下临时文件
+			for _, name := range remoteNames(t, storage) {
+				t.Errorf("leftover file: %s", name)
+			}
+		})
+	}
+}
+
+func TestSFTPStorageKeepsExistingBackupWhenReplaceFails(t *testing.T) {
+	for _, posixRename := range []bool{true, false} {
+		t.Run("posixRename="+strconv.FormatBool(posixRename), func(t *testing.T) {
+			server, storage := newFakeSFTPStorage(t, posixRename)
+			name := "Farm_1.zip"
+			put(t, storage, name, []byte("old"))
+
+			// 替换失败时原来的备份必须保留
+			server.failRename["/backups/stardew/"+name] = true
+			data := randomBytes(t, 1000)
+			if err := storage.Put(context.Background(), name, strings.NewReader(string(data)), int64(len(data))); err == nil {
+				t.Fatal("Put succeeded despite the failed rename")
+			}
+			if got := readBackup(t, storage, name); string(got) != "old" {
+				t.Errorf("content after failed replace = %q, want old content", got)
+			}
+			if names := remoteNames(t, storage); len(names) != 1 {
+				t.Errorf("files after failed replace = %q, want only %s", names, name)
+			}
+
+			put(t, storage, name, data)
+			if got := readBackup(t, storage, name); string(got) != string(data) {
+				t.Error("retry did not replace the backup")
+			}
+		})
+	}
+}
+
+func TestSFTPStorageRejectsWrongHostKey(t *testing.T) {
+	_, config := startFakeSFTPServer(t, true)
+	_, other := startFakeSFTPServer(t, true)
+	config.HostKey = other.HostKey
+	storage, err := newSFTPStorage(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.List(context.Background()); err == nil {
+		t.Error("List succeeded with the wrong host key")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+)
+
+// testBackupStorageRoundTrip 对存储依次执行写入、覆盖、列出、读取和删除，所有存储实现都应通过
+func testBackupStorageRoundTrip(t *testing.T, storage BackupStorage) {
+	t.Helper()
+	ctx := context.Background()
+
+	first := "Farm_1_20240101_120000.zip"
+	second := "Farm_2_20240102_080000.zip.enc"
+	// 超过 SFTP 单次读写的大小，覆盖分多次传输的情况
+	contents := map[string][]byte{
+		first:  randomBytes(t, 100*1024+3),
+		second: randomBytes(t, 10),
+	}
+	for _, name := range []string{first, second} {
+		put(t, storage, name, []byte("old content"))
+		put(t, storage, name, contents[name])
+	}
+
+	backups, err := storage.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name < backups[j].Name })
+	if len(backups) != 2 || backups[0].Name != first || backups[1].Name != second {
+		t.Fatalf("List = %+v, want %s and %s", backups, first, second)
+	}
+	for _, backup := range backups {
+		if backup.Size != int64(len(contents[backup.Name])) {
+			t.Errorf("%s: size = %d, want %d", backup.Name, backup.Size, len(contents[backup.Name]))
+		}
+		if backup.ModTime.IsZero() {
+			t.Errorf("%s: missing modification time", backup.Name)
+		}
+	}
+
+	for name, want := range contents {
+		if got := readBackup(t, storage, name); !bytes.Equal(got, want) {
+			t.Errorf("%s: read %d bytes, want the %d bytes written last", name, len(got), len(want))
+		}
+	}
+	if _, err := storage.Open(ctx, "missing.zip"); !errors.Is(err, errBackupNotFound) {
+		t.Errorf("Open(missing) = %v, want errBackupNotFound", err)
+	}
+
+	if err := storage.Delete(ctx, first); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := storage.Open(ctx, first); !errors.Is(err, errBackupNotFound) {
+		t.Errorf("Open(deleted) = %v, want errBackupNotFound", err)
+	}
+	backups, err = storage.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != second {
+		t.Errorf("List after delete = %+v, want only %s", backups, second)
+	}
+	if err := storage.Delete(ctx, second); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+}
+
+func put(t *testing.T, storage BackupStorage, name string, data []byte) {
+	t.Helper()
+	if err := storage.Put(context.Background(), name, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Put(%s): %v", name, err)
+	}
+}
+
+func readBackup(t *testing.T, storage BackupStorage, name string) []byte {
+	t.Helper()
+	reader, err := storage.Open(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", name, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return data
+}
+
+func TestLocalStorage(t *testing.T) {
+	testBackupStorageRoundTrip(t, newLocalStorage("local", t.TempDir()))
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TransferSave 在存档库或路径之间移动/复制存档
+func (s *SaveService) TransferSave(c *gin.Context) {
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请求参数无效",
+		})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = "copy"
+	}
+	if req.Mode != "copy" && req.Mode != "move" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "转移模式只能是 copy 或 move",
+		})
+		return
+	}
+
+	lib, ok := s.requestLibrary(c)
+	if !ok {
+		return
+	}
+
+	// 确定目标根目录：优先使用存档库，其次使用指定路径
+	var targetRoot string
+	switch {
+	case req.TargetLibrary != "":
+		target, ok := s.getLibrary(req.TargetLibrary)
+		if !ok {
+			c.JSON(http.StatusNotFound, APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("存档库不存在: %s", req.TargetLibrary),
+			})
+			return
+		}
+		targetRoot = target.Path
+	case req.TargetPath != "":
+		targetRoot = req.TargetPath
+	default:
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "请指定目标存档库或目标路径",
+		})
+		return
+	}
+
+	if !s.isValidPath(targetRoot) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "目标路径无效或不安全",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	save, err := s.getSaveByID(lib.Path, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Error:   "存档不存在",
+		})
+		return
+	}
+
+	backup := req.BackupExisting == nil || *req.BackupExisting
+	result, err := s.transferSave(save, targetRoot, req.Mode == "move", req.OverwriteExisting, backup)
+	if err != nil {
+		s.addLog("transfer", fmt.Sprintf("转移存档失败(%s): %s -> %s", req.Mode, save.Name, targetRoot), false, err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, errTransferIntoSource) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Error:   "转移存档失败: " + err.Error(),
+		})
+		return
+	}
+
+	s.addLog("transfer", fmt.Sprintf("转移存档(%s): %s -> %s", req.Mode, save.Name, targetRoot), true, result.Warning)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "存档转移成功",
+		Data:    result,
+	})
+}
+
+// errTransferIntoSource 目标位置位于源存档内或包含源存档
+var errTransferIntoSource = errors.New("目标位置不能位于源存档内，也不能包含源存档")
+
+// transferSave 将存档复制到目标根目录，校验通过后再替换目标，移动模式下最后删除源存档
+func (s *SaveService) transferSave(save *SaveInfo, targetRoot string, move, overwrite, backup bool) (*TransferResult, error) {
+	targetPath := filepath.Join(targetRoot, save.Name)
+
+	srcAbs, err := resolvePath(save.Path)
+	if err != nil {
+		return nil, err
+	}
+	rootAbs, err := resolvePath(targetRoot)
+	if err != nil {
+		return nil, err
+	}
+	dstAbs := filepath.Join(rootAbs, save.Name)
+	if srcAbs == dstAbs {
+		return nil, fmt.Errorf("源存档和目标位置相同")
+	}
+	// 目标根目录在源存档内时，复制会遍历到正在写入的暂存目录，移动时删除源存档也会删除新的副本；
+	// 目标存档目录包含源存档时，替换目标会把源存档一起移走
+	if isWithinDir(srcAbs, rootAbs) || isWithinDir(dstAbs, srcAbs) {
+		return nil, errTransferIntoSource
+	}
+
+	exists, backupPath, err := s.checkSaveConflict(targetPath, overwrite, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先复制到目标根目录下的临时目录，保证最终替换是同一文件系统内的重命名
+	stagingPath := filepath.Join(targetRoot, stagingPrefix+"transfer_"+uuid.New().String())
+	if err := copyDirectory(save.Path, stagingPath); err != nil {
+		os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("复制存档失败: %v", err)
+	}
+
+	if err := verifyDirectoryCopy(save.Path, stagingPath); err != nil {
+		os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("复制校验失败: %v", err)
+	}
+
+	staged := s.parseSaveDirectory(targetRoot, stagingPath)
+	if save.IsValid && !staged.IsValid {
+		os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("复制后的存档无法解析: %s", staged.Error)
+	}
+
+	if err := swapDirectory(stagingPath, targetPath); err != nil {
+		os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("替换目标存档失败: %v", err)
+	}
+
+	result := &TransferResult{
+		Mode:       "copy",
+		Source:     *save,
+		Target:     s.parseSaveDirectory(targetRoot, targetPath),
+		Overwrite:  exists,
+		BackupPath: backupPath,
+	}
+
+	if move {
+		result.Mode = "move"
+		if !s.isAllowedPath(save.Path) {
+			result.Warning = "源存档路径不在允许的目录内，未删除源存档"
+		} else if err := os.RemoveAll(save.Path); err != nil {
+			// 复制已经成功，源存档删除失败只作为警告返回
+			result.Warning = "删除源存档失败: " + err.Error()
+		}
+	}
+
+	return result, nil
+}
+
+// swapDirectory 用暂存目录替换目标目录，替换失败时恢复原目录。
+// 原目录暂时改名为 replacedPrefix 开头的隐藏目录，异常退出后由 cleanupStagingDirs 处理
+func swapDirectory(stagingPath, targetPath string) error {
+	var replacedPath string
+	if _, err := os.Stat(targetPath); err == nil {
+		replacedPath = filepath.Join(filepath.Dir(targetPath), replacedPrefix+uuid.New().String()+"_"+filepath.Base(targetPath))
+		if err := os.Rename(targetPath, replacedPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(stagingPath, targetPath); err != nil {
+		if replacedPath != "" {
+			os.Rename(replacedPath, targetPath)
+		}
+		return err
+	}
+
+	if replacedPath != "" {
+		os.RemoveAll(replacedPath)
+	}
+	return nil
+}
+
+// copyDirectory 递归复制目录，保留文件权限和修改时间
+func copyDirectory(sourceDir, targetDir string) error {
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(targetDir, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(targetPath, info.Mode().Perm()|0700)
+		}
+
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("不支持的文件类型: %s", relPath)
+		}
+
+		if err := copyFile(path, targetPath, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(targetPath, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	// 保留存档目录的修改时间，最后游玩时间依赖它
+	if info, err := os.Stat(sourceDir); err == nil {
+		os.Chtimes(targetDir, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// copyFile 复制单个文件并同步到磁盘
+func copyFile(sourcePath, targetPath string, perm os.FileMode) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	if err := target.Sync(); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// verifyDirectoryCopy 逐个文件比较 SHA-256，确认副本与源目录完全一致
+func verifyDirectoryCopy(sourceDir, targetDir string) error {
+	sourceFiles := 0
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		sourceFiles++
+
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+
+		sourceHash, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		targetHash, err := fileSHA256(filepath.Join(targetDir, relPath))
+		if err != nil {
+			return fmt.Errorf("副本缺少文件 %s: %v", relPath, err)
+		}
+		if sourceHash != targetHash {
+			return fmt.Errorf("文件内容不一致: %s", relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	targetFiles := 0
+	err = filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			targetFiles++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if sourceFiles != targetFiles {
+		return fmt.Errorf("文件数量不一致: 源 %d 个, 副本 %d 个", sourceFiles, targetFiles)
+	}
+	return nil
+}
+
+// fileSHA256 计算文件的 SHA-256 校验值
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
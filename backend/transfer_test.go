@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestSave 在 root 下创建存档目录，主存档文件的内容为 content
+func writeTestSave(t *testing.T, root, name, content string) string {
+	t.Helper()
+	savePath := filepath.Join(root, name)
+	if err := os.MkdirAll(savePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{name: content, "SaveGameInfo": "<Farmer />", name + "_old": content}
+	for file, data := range files {
+		if err := os.WriteFile(filepath.Join(savePath, file), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return savePath
+}
+
+func readMainFile(t *testing.T, savePath string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(savePath, filepath.Base(savePath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// dirNames 返回目录中的所有条目名称
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestSwapDirectory(t *testing.T) {
+	root := t.TempDir()
+	targetPath := writeTestSave(t, root, "Farm_1", "old")
+	stagingPath := writeTestSave(t, root, stagingPrefix+"test", "new")
+	os.Rename(filepath.Join(stagingPath, stagingPrefix+"test"), filepath.Join(stagingPath, "Farm_1"))
+
+	if err := swapDirectory(stagingPath, targetPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := readMainFile(t, targetPath); got != "new" {
+		t.Errorf("target content = %q, want new", got)
+	}
+	// 替换完成后不留下原目录和暂存目录
+	if names := dirNames(t, root); len(names) != 1 || names[0] != "Farm_1" {
+		t.Errorf("root contains %v, want only Farm_1", names)
+	}
+
+	// 目标不存在时直接改名
+	stagingPath = writeTestSave(t, root, stagingPrefix+"second", "second")
+	if err := swapDirectory(stagingPath, filepath.Join(root, "Farm_2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "Farm_2", "SaveGameInfo")); err != nil {
+		t.Errorf("Farm_2 was not created: %v", err)
+	}
+}
+
+func TestSwapDirectoryRestoresTargetOnFailure(t *testing.T) {
+	root := t.TempDir()
+	targetPath := writeTestSave(t, root, "Farm_1", "old")
+
+	err := swapDirectory(filepath.Join(root, stagingPrefix+"missing"), targetPath)
+	if err == nil {
+		t.Fatal("swap with a missing staging directory succeeded")
+	}
+	if got := readMainFile(t, targetPath); got != "old" {
+		t.Errorf("target content = %q, want the original", got)
+	}
+	if names := dirNames(t, root); len(names) != 1 || names[0] != "Farm_1" {
+		t.Errorf("root contains %v, want only Farm_1", names)
+	}
+}
+
+func TestCleanupStagingDirs(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	// 两次重命名之间中断：目标已经不存在，原目录是唯一的副本
+	interrupted := writeTestSave(t, root, replacedPrefix+"0b6a3f4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b_Farm_1", "original")
+	os.Rename(filepath.Join(interrupted, filepath.Base(interrupted)), filepath.Join(interrupted, "Farm_1"))
+	// 替换已经完成但没来得及删除原目录
+	writeTestSave(t, root, "Farm_2", "new")
+	writeTestSave(t, root, replacedPrefix+"1b6a3f4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b_Farm_2", "replaced")
+	// 过期和还在使用的暂存目录
+	stale := writeTestSave(t, root, stagingPrefix+"import_stale", "stale")
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	writeTestSave(t, root, stagingPrefix+"import_fresh", "fresh")
+
+	cleanupStagingDirs(root)
+
+	want := []string{stagingPrefix + "import_fresh", "Farm_1", "Farm_2"}
+	if names := dirNames(t, root); strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("root contains %v, want %v", names, want)
+	}
+	if got := readMainFile(t, filepath.Join(root, "Farm_1")); got != "original" {
+		t.Errorf("restored Farm_1 = %q, want the original content", got)
+	}
+	if got := readMainFile(t, filepath.Join(root, "Farm_2")); got != "new" {
+		t.Errorf("Farm_2 = %q, want the replacement", got)
+	}
+}
+
+func TestTransferSave(t *testing.T) {
+	base := t.TempDir()
+	source := filepath.Join(base, "source")
+	target := filepath.Join(base, "target")
+	os.MkdirAll(target, 0755)
+	writeTestSave(t, source, "Farm_1", diffBase16Save)
+	s := newRootedService(t, base)
+
+	getSave := func() *SaveInfo {
+		t.Helper()
+		save, err := s.getSaveByID(source, "Farm_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return save
+	}
+
+	result, err := s.transferSave(getSave(), target, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Mode != "copy" || result.Overwrite || !result.Target.IsValid || result.Target.PlayerName != "Alice" {
+		t.Errorf("copy result = %+v", result)
+	}
+	if got := readMainFile(t, filepath.Join(target, "Farm_1")); got != diffBase16Save {
+		t.Error("copied save differs from the source")
+	}
+
+	// 目标已存在时不覆盖就报错，且不留下暂存目录
+	if _, err := s.transferSave(getSave(), target, false, false, false); err == nil || !strings.Contains(err.Error(), "存档已存在") {
+		t.Errorf("copy onto an existing save: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(source, "Farm_1", "Farm_1"), []byte(diffTarget16Save), 0644)
+	result, err = s.transferSave(getSave(), target, true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Mode != "move" || !result.Overwrite || result.Warning != "" {
+		t.Errorf("move result = %+v", result)
+	}
+	if got := readMainFile(t, filepath.Join(target, "Farm_1")); got != diffTarget16Save {
+		t.Error("target was not replaced by the moved save")
+	}
+	if _, err := os.Stat(filepath.Join(source, "Farm_1")); !os.IsNotExist(err) {
+		t.Errorf("source still exists after move: %v", err)
+	}
+	if names := dirNames(t, target); len(names) != 1 || names[0] != "Farm_1" {
+		t.Errorf("target contains %v, want only Farm_1", names)
+	}
+}
+
+func TestTransferSaveRejectsSourceOverlap(t *testing.T) {
+	base := t.TempDir()
+	source := filepath.Join(base, "saves")
+	savePath := writeTestSave(t, source, "Farm_1", diffBase16Save)
+	s := newRootedService(t, base)
+	save, err := s.getSaveByID(source, "Farm_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.transferSave(save, source, false, true, false); err == nil {
+		t.Error("transfer onto itself succeeded")
+	}
+	if _, err := s.transferSave(save, savePath, false, true, false); !errors.Is(err, errTransferIntoSource) {
+		t.Errorf("transfer into the source: %v, want errTransferIntoSource", err)
+	}
+	if names := dirNames(t, savePath); len(names) != 3 {
+		t.Errorf("source contains %v after rejected transfers", names)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadSize 单次上传的大小限制
+const maxUploadSize = 100 * 1024 * 1024 // 100MB
+
+// 支持导入的压缩包格式
+const (
+	archiveFormatZip   = "zip"
+	archiveFormatTar   = "tar"
+	archiveFormatTarGz = "tar.gz"
+)
+
+// uploadArchiveFormat 根据文件名判断压缩包格式，加密文件按去掉 .enc 后的文件名判断，不支持时返回空字符串
+func uploadArchiveFormat(filename string) string {
+	lower := strings.TrimSuffix(strings.ToLower(filename), encryptedSuffix)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveFormatZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveFormatTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveFormatTar
+	}
+	return ""
+}
+
+// archiveStem 去掉压缩包扩展名后的文件名
+func archiveStem(filename string) string {
+	if strings.HasSuffix(strings.ToLower(filename), encryptedSuffix) {
+		filename = filename[:len(filename)-len(encryptedSuffix)]
+	}
+	lower := strings.ToLower(filename)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return filename[:len(filename)-len(ext)]
+		}
+	}
+	return filename
+}
+
+// newTempDir 在 ./temp 下创建独立的临时目录，每次上传或转换各用一个，避免同名文件互相覆盖
+func newTempDir(prefix string) (string, error) {
+	if err := os.MkdirAll("./temp", 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp("./temp", prefix)
+}
+
+// receiveUpload 校验并保存上传的存档，tar、tar.gz 和文件夹上传都会先转换成ZIP，
+// 之后统一走ZIP的导入流程。返回的清理函数会删除本次上传的所有临时文件，失败时直接写入错误响应
+func (s *SaveService) receiveUpload(c *gin.Context) (string, string, func(), bool) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "文件上传失败",
+		})
+		return "", "", nil, false
+	}
+
+	files := form.File["file"]
+	if len(files) == 1 && len(form.Value["paths"]) == 0 && uploadArchiveFormat(files[0].Filename) == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "只支持 ZIP、TAR 和 TAR.GZ 格式的存档文件",
+		})
+		return "", "", nil, false
+	}
+
+	tempDir, err := newTempDir("upload_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "创建临时目录失败",
+		})
+		return "", "", nil, false
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	// 多个文件或带有 paths 字段时按文件夹上传处理
+	var zipPath, filename string
+	var ok bool
+	if len(files) > 1 || len(form.Value["paths"]) > 0 {
+		zipPath, filename, ok = s.receiveFolderUpload(c, files, form.Value["paths"], tempDir)
+	} else {
+		zipPath, filename, ok = s.receiveArchiveUpload(c, files[0], tempDir)
+	}
+	if !ok {
+		cleanup()
+		return "", "", nil, false
+	}
+
+	return zipPath, filename, cleanup, true
+}
+
+// receiveArchiveUpload 保存上传的单个压缩包，tar 格式转换成ZIP
+func (s *SaveService) receiveArchiveUpload(c *gin.Context, file *multipart.FileHeader, tempDir string) (string, string, bool) {
+	// 文件大小限制 (100MB)
+	if file.Size > maxUploadSize {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "文件大小超过限制(100MB)，更大的文件请使用分块上传",
+		})
+		return "", "", false
+	}
+
+	// 保存上传的文件，保留原文件名以便推断存档名称
+	tempPath := filepath.Join(tempDir, filepath.Base(file.Filename))
+	if err := c.SaveUploadedFile(file, tempPath); err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "保存文件失败",
+		})
+		return "", "", false
+	}
+
+	zipPath, err := s.convertToZip(tempPath, tempDir)
+	if err != nil {
+		s.respondConvertError(c, err)
+		return "", "", false
+	}
+
+	return zipPath, file.Filename, true
+}
+
+// convertToZip 把服务器上的压缩包或目录整理成ZIP：ZIP文件直接返回原路径，
+// tar、tar.gz 和目录转换后写入 tempDir。加密的文件先解密到 tempDir 再按原格式处理
+func (s *SaveService) convertToZip(srcPath, tempDir string) (string, error) {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(srcPath)
+	if info.IsDir() {
+		zipPath := filepath.Join(tempDir, name+".zip")
+		return zipPath, s.zipLocalDirectory(srcPath, zipPath)
+	}
+
+	if isEncryptedFile(srcPath) {
+		// 解密到单独的子目录，保留去掉 .enc 后的文件名用于判断格式和推断存档名称
+		decryptedDir := filepath.Join(tempDir, "decrypted")
+		if err := os.MkdirAll(decryptedDir, 0755); err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(strings.ToLower(name), encryptedSuffix) {
+			name = name[:len(name)-len(encryptedSuffix)]
+		}
+		decryptedPath := filepath.Join(decryptedDir, name)
+		if err := decryptFile(srcPath, decryptedPath); err != nil {
+			return "", err
+		}
+		srcPath = decryptedPath
+	}
+
+	format := uploadArchiveFormat(name)
+	switch format {
+	case archiveFormatZip:
+		return srcPath, nil
+	case archiveFormatTar, archiveFormatTarGz:
+		zipPath := filepath.Join(tempDir, archiveStem(name)+".zip")
+		return zipPath, convertTarToZip(srcPath, zipPath, format == archiveFormatTarGz)
+	}
+	return "", fmt.Errorf("不支持的文件格式: %s", name)
+}
+
+// zipLocalDirectory 把目录连同目录名一起打包成ZIP，目录中有符号链接或特殊文件时拒绝
+func (s *SaveService) zipLocalDirectory(sourceDir, zipPath string) error {
+	var issues []ArchiveEntryIssue
+	fileCount := 0
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			issues = append(issues, ArchiveEntryIssue{Name: path, Reason: "符号链接"})
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			issues = append(issues, ArchiveEntryIssue{Name: path, Reason: "不支持的文件类型"})
+		} else if !info.IsDir() {
+			fileCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if fileCount > maxImportFiles {
+		issues = append(issues, ArchiveEntryIssue{
+			Name:   "*",
+			Reason: fmt.Sprintf("文件数量 %d 超过限制 %d", fileCount, maxImportFiles),
+		})
+	}
+	if len(issues) > 0 {
+		return &ArchiveValidationError{Issues: issues}
+	}
+
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zipWriter := zip.NewWriter(out)
+	if err := s.addDirectoryToZip(zipWriter, sourceDir, filepath.Base(sourceDir)); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// receiveFolderUpload 把浏览器上传的存档文件夹打包成ZIP。浏览器提交的相对路径会被
+// multipart 解析时去掉目录部分，所以需要前端在 paths 字段中按顺序提供每个文件的相对路径
+func (s *SaveService) receiveFolderUpload(c *gin.Context, files []*multipart.FileHeader, paths []string, tempDir string) (string, string, bool) {
+	if len(paths) > 0 && len(paths) != len(files) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("paths 数量(%d)与文件数量(%d)不一致", len(paths), len(files)),
+		})
+		return "", "", false
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+	if totalSize > maxUploadSize {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "文件大小超过限制(100MB)",
+		})
+		return "", "", false
+	}
+
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Filename
+		if len(paths) > 0 {
+			names[i] = paths[i]
+		}
+	}
+
+	// 压缩包以上传的顶级目录命名，文件都在根目录时由主存档文件名决定存档名称
+	folderName := "folder_upload"
+	if first := cleanArchiveName(names[0]); strings.Contains(first, "/") {
+		folderName = strings.Split(first, "/")[0]
+	}
+
+	zipPath := filepath.Join(tempDir, filepath.Base(folderName)+".zip")
+	if err := writeFolderUploadZip(files, names, zipPath); err != nil {
+		s.respondConvertError(c, err)
+		return "", "", false
+	}
+
+	return zipPath, folderName, true
+}
+
+// respondConvertError 返回压缩包转换失败的响应
+func (s *SaveService) respondConvertError(c *gin.Context, err error) {
+	var validationErr *ArchiveValidationError
+	if errors.As(err, &validationErr) {
+		s.respondImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, APIResponse{
+		Success: false,
+		Error:   "解析上传内容失败: " + err.Error(),
+	})
+}
+
+// writeFolderUploadZip 按给定的相对路径把上传的文件写入ZIP，先检查所有路径再写入
+func writeFolderUploadZip(files []*multipart.FileHeader, names []string, zipPath string) error {
+	var issues []ArchiveEntryIssue
+	for _, name := range names {
+		if reason := checkArchiveEntryName(name); reason != "" {
+			issues = append(issues, ArchiveEntryIssue{Name: name, Reason: reason})
+		}
+	}
+	if len(files) > maxImportFiles {
+		issues = append(issues, ArchiveEntryIssue{
+			Name:   "*",
+			Reason: fmt.Sprintf("文件数量 %d 超过限制 %d", len(files), maxImportFiles),
+		})
+	}
+	if len(issues) > 0 {
+		return &ArchiveValidationError{Issues: issues}
+	}
+
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zipWriter := zip.NewWriter(out)
+	for i, file := range files {
+		if err := addUploadedFileToZip(zipWriter, file, cleanArchiveName(names[i])); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// addUploadedFileToZip 将单个上传的文件写入ZIP
+func addUploadedFileToZip(zipWriter *zip.Writer, file *multipart.FileHeader, name string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	writer, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, src)
+	return err
+}
+
+// convertTarToZip 把 tar 或 tar.gz 转换成ZIP。转换时按与ZIP相同的规则检查每个条目，
+// 拒绝路径穿越、链接和特殊文件，并限制文件数量和解压后的总大小
+func convertTarToZip(tarPath, zipPath string, gzipped bool) error {
+	in, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var src io.Reader = bufio.NewReader(in)
+	if gzipped {
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			return fmt.Errorf("解压gzip失败: %v", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zipWriter := zip.NewWriter(out)
+	tarReader := tar.NewReader(src)
+	budget := newExtractBudget()
+	var issues []ArchiveEntryIssue
+	fileCount := 0
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取tar失败: %v", err)
+		}
+
+		if reason := checkArchiveEntryName(header.Name); reason != "" {
+			issues = append(issues, ArchiveEntryIssue{Name: header.Name, Reason: reason})
+			continue
+		}
+
+		name := cleanArchiveName(header.Name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if name == "" || name == "." {
+				continue
+			}
+			if _, err := zipWriter.Create(name + "/"); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeReg:
+		case tar.TypeSymlink, tar.TypeLink:
+			issues = append(issues, ArchiveEntryIssue{Name: header.Name, Reason: "符号链接"})
+			continue
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			issues = append(issues, ArchiveEntryIssue{Name: header.Name, Reason: "不支持的文件类型"})
+			continue
+		}
+
+		fileCount++
+		if fileCount > maxImportFiles {
+			issues = append(issues, ArchiveEntryIssue{
+				Name:   "*",
+				Reason: fmt.Sprintf("文件数量超过限制 %d", maxImportFiles),
+			})
+			break
+		}
+
+		// 有问题的条目已经决定了结果，只继续收集问题而不再写入
+		if len(issues) > 0 {
+			continue
+		}
+
+		zipHeader := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: header.ModTime,
+		}
+		zipHeader.SetMode(os.FileMode(header.Mode).Perm())
+		writer, err := zipWriter.CreateHeader(zipHeader)
+		if err != nil {
+			return err
+		}
+		if err := budget.copy(writer, tarReader); err != nil {
+			return err
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ArchiveValidationError{Issues: issues}
+	}
+	return zipWriter.Close()
+}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,7 +15,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
@@ -34,43 +38,59 @@ type Player struct {
 	MillisecondsPlayed int64  `xml:"millisecondsPlayed"`
 }
 
-// isValidPath 验证路径是否安全和有效
+// isValidPath 验证路径是否安全和有效：必须是存在的目录，且解析符号链接后位于允许的根目录内
 func (s *SaveService) isValidPath(path string) bool {
 	if path == "" {
 		return false
 	}
 
-	// 清理路径
-	cleanPath := filepath.Clean(path)
+	// 检查路径是否存在且可访问
+	info, err := os.Stat(filepath.Clean(path))
+	if err != nil || !info.IsDir() {
+		return false
+	}
+
+	return s.isAllowedPath(path)
+}
 
-	// 获取绝对路径以进行安全检查
-	absPath, err := filepath.Abs(cleanPath)
+// isAllowedPath 检查路径解析符号链接后是否位于某个允许的根目录内
+func (s *SaveService) isAllowedPath(path string) bool {
+	resolved, err := resolvePath(path)
 	if err != nil {
 		return false
 	}
 
-	// 检查是否是危险的路径遍历 (防止访问系统关键目录)
-	// 允许相对路径，但不能访问根目录或系统目录
-	if strings.HasPrefix(absPath, "/") && (absPath == "/" ||
-		strings.HasPrefix(absPath, "/etc") ||
-		strings.HasPrefix(absPath, "/bin") ||
-		strings.HasPrefix(absPath, "/usr/bin") ||
-		strings.HasPrefix(absPath, "/sys") ||
-		strings.HasPrefix(absPath, "/proc")) {
-		return false
+	for _, root := range s.allowedRoots() {
+		resolvedRoot, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		if isWithinDir(resolvedRoot, resolved) {
+			return true
+		}
 	}
+	return false
+}
 
-	// 检查路径是否存在且可访问
-	info, err := os.Stat(cleanPath)
+// resolvePath 获取路径的绝对形式并解析其中的符号链接
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(filepath.Clean(path))
 	if err != nil {
-		return false
+		return "", err
 	}
+	return filepath.EvalSymlinks(absPath)
+}
 
-	// 必须是目录
-	return info.IsDir()
+// isWithinDir 判断 path 是否等于 root 或位于 root 之下（两者均需为已解析的绝对路径）
+func isWithinDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
 }
 
-// addToRecentPaths 添加到最近路径列表
+// addToRecentPaths 添加到最近路径列表，调用方需持有写锁
 func (s *SaveService) addToRecentPaths(path string) {
 	// 检查是否已存在
 	for i, recent := range s.recentPaths {
@@ -90,6 +110,16 @@ func (s *SaveService) addToRecentPaths(path string) {
 	}
 }
 
+// getRecentPaths 获取最近路径列表的副本
+func (s *SaveService) getRecentPaths() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths := make([]string, len(s.recentPaths))
+	copy(paths, s.recentPaths)
+	return paths
+}
+
 // addLog 添加操作日志
 func (s *SaveService) addLog(operation, details string, success bool, errorMsg string) {
 	log := OperationLog{
@@ -101,6 +131,9 @@ func (s *SaveService) addLog(operation, details string, success bool, errorMsg s
 		Error:     errorMsg,
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.logs = append(s.logs, log)
 
 	// 限制日志数量
@@ -110,25 +143,26 @@ func (s *SaveService) addLog(operation, details string, success bool, errorMsg s
 }
 
 // scanSaves 扫描存档目录
-func (s *SaveService) scanSaves() ([]SaveInfo, error) {
+func (s *SaveService) scanSaves(rootPath string) ([]SaveInfo, error) {
 	var saves []SaveInfo
 
-	if !s.isValidPath(s.currentPath) {
-		return saves, fmt.Errorf("当前路径无效: %s", s.currentPath)
+	if !s.isValidPath(rootPath) {
+		return saves, fmt.Errorf("当前路径无效: %s", rootPath)
 	}
 
-	entries, err := os.ReadDir(s.currentPath)
+	entries, err := os.ReadDir(rootPath)
 	if err != nil {
 		return saves, err
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		// 跳过文件以及转移/导入过程中的隐藏暂存目录
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
-		savePath := filepath.Join(s.currentPath, entry.Name())
-		saveInfo := s.parseSaveDirectory(savePath)
+		savePath := filepath.Join(rootPath, entry.Name())
+		saveInfo := s.parseSaveDirectory(rootPath, savePath)
 		saves = append(saves, saveInfo)
 	}
 
@@ -136,9 +170,9 @@ func (s *SaveService) scanSaves() ([]SaveInfo, error) {
 }
 
 // parseSaveDirectory 解析存档目录
-func (s *SaveService) parseSaveDirectory(savePath string) SaveInfo {
+func (s *SaveService) parseSaveDirectory(rootPath, savePath string) SaveInfo {
 	saveInfo := SaveInfo{
-		ID:      s.generateSaveID(savePath),
+		ID:      s.generateSaveID(rootPath, savePath),
 		Name:    filepath.Base(savePath),
 		Path:    savePath,
 		IsValid: false,
@@ -164,7 +198,12 @@ func (s *SaveService) parseSaveDirectory(savePath string) SaveInfo {
 		return saveInfo
 	}
 
-	// 填充存档信息
+	s.fillSaveInfo(&saveInfo, gameData)
+	return saveInfo
+}
+
+// fillSaveInfo 用解析出的存档数据填充存档信息
+func (s *SaveService) fillSaveInfo(saveInfo *SaveInfo, gameData *StardewSaveGame) {
 	saveInfo.PlayerName = gameData.Player.Name
 	saveInfo.FarmName = gameData.Player.FarmName
 	saveInfo.Money = gameData.Player.Money
@@ -174,8 +213,6 @@ func (s *SaveService) parseSaveDirectory(savePath string) SaveInfo {
 	saveInfo.Year = gameData.Year
 	saveInfo.PlayTime = s.formatPlayTime(gameData.Player.MillisecondsPlayed)
 	saveInfo.IsValid = true
-
-	return saveInfo
 }
 
 // findMainSaveFile 查找主存档文件
@@ -199,18 +236,23 @@ func (s *SaveService) findMainSaveFile(savePath string) string {
 			continue
 		}
 
-		name := entry.Name()
-		// 查找没有扩展名或扩展名为.xml的文件，且不是临时文件
-		if !strings.Contains(name, ".") || strings.HasSuffix(name, ".xml") {
-			if !strings.HasPrefix(name, "SaveGameInfo") && !strings.HasSuffix(name, "_old") {
-				return filepath.Join(savePath, name)
-			}
+		if isCandidateSaveFileName(entry.Name()) {
+			return filepath.Join(savePath, entry.Name())
 		}
 	}
 
 	return ""
 }
 
+// isCandidateSaveFileName 判断文件名是否可能是主存档文件：
+// 没有扩展名或扩展名为.xml，且不是 SaveGameInfo 或 _old 备份
+func isCandidateSaveFileName(name string) bool {
+	if strings.Contains(name, ".") && !strings.HasSuffix(name, ".xml") {
+		return false
+	}
+	return !strings.HasPrefix(name, "SaveGameInfo") && !strings.HasSuffix(name, "_old")
+}
+
 // parseSaveFile 解析存档文件
 func (s *SaveService) parseSaveFile(filePath string) (*StardewSaveGame, error) {
 	data, err := os.ReadFile(filePath)
@@ -259,16 +301,16 @@ func (s *SaveService) calculateDirectorySize(dirPath string) int64 {
 }
 
 // generateSaveID 生成存档ID
-func (s *SaveService) generateSaveID(savePath string) string {
+func (s *SaveService) generateSaveID(rootPath, savePath string) string {
 	// 使用路径的相对部分作为ID的基础
-	relPath, _ := filepath.Rel(s.currentPath, savePath)
+	relPath, _ := filepath.Rel(rootPath, savePath)
 	// 简单的ID生成，实际项目中可能需要更复杂的方案
 	return strings.ReplaceAll(relPath, string(filepath.Separator), "_")
 }
 
 // getSaveByID 根据ID获取存档
-func (s *SaveService) getSaveByID(id string) (*SaveInfo, error) {
-	saves, err := s.scanSaves()
+func (s *SaveService) getSaveByID(rootPath, id string) (*SaveInfo, error) {
+	saves, err := s.scanSaves(rootPath)
 	if err != nil {
 		return nil, err
 	}
@@ -282,72 +324,68 @@ func (s *SaveService) getSaveByID(id string) (*SaveInfo, error) {
 	return nil, fmt.Errorf("存档不存在")
 }
 
-// createBackup 创建备份
-func (s *SaveService) createBackup(sourcePath, backupPath string) error {
-	return s.createZipFromDirectory(sourcePath, backupPath)
-}
+// createBackup 创建备份，返回备份目录中的路径
+func (s *SaveService) createBackup(sourcePath, name string) (string, error) {
+	// 开启了存档历史时先快照
+	s.autoSnapshot(sourcePath)
 
-// createZipFromDirectory 从目录创建ZIP文件
-func (s *SaveService) createZipFromDirectory(sourceDir, zipPath string) error {
-	zipFile, err := os.Create(zipPath)
+	// 配置了加密时备份会被加密，文件名追加 .enc
+	secret, err := encryptionSecret()
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// 计算相对路径
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
-		}
-
-		// 跳过根目录
-		if relPath == "." {
-			return nil
-		}
-
-		// 标准化路径分隔符
-		relPath = strings.ReplaceAll(relPath, "\\", "/")
+	name = encryptedName(name, secret)
 
-		if info.IsDir() {
-			// 创建目录条目
-			relPath += "/"
-			_, err := zipWriter.Create(relPath)
-			return err
-		}
-
-		// 创建文件条目
-		writer, err := zipWriter.Create(relPath)
-		if err != nil {
-			return err
-		}
+	tempDir, err := newTempDir("backup_")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+	tempPath := filepath.Join(tempDir, name)
 
-		// 复制文件内容
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	save := s.parseSaveDirectory(filepath.Dir(sourcePath), sourcePath)
+	writer, closeFile, err := createArchiveFile(tempPath, secret)
+	if err != nil {
+		return "", err
+	}
 
-		_, err = io.Copy(writer, file)
-		return err
-	})
+	// 备份中同样附带导出清单，可以像导出的存档一样导入并校验
+	opts := s.zipOptions()
+	zipWriter := opts.newZipWriter(writer)
+	err = s.writeSaveToZip(context.Background(), zipWriter, opts, save, "", "", noProgress{})
+	if closeErr := zipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := closeFile(); err == nil {
+		err = closeErr
+	}
+	// 写入备份目录后在后台复制到复制目标
+	if err == nil {
+		err = s.storeBackup(context.Background(), tempPath, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(backupsDir, name), nil
 }
 
 // addDirectoryToZip 将目录添加到ZIP
 func (s *SaveService) addDirectoryToZip(zipWriter *zip.Writer, sourceDir, dirName string) error {
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+	_, err := s.writeDirectoryToZip(context.Background(), zipWriter, zipOptions{}, sourceDir, dirName, noProgress{})
+	return err
+}
+
+// writeDirectoryToZip 将目录按文件名顺序添加到ZIP，每写完一个文件报告一次进度，ctx 取消后停止。
+// 返回写入的每个文件的大小和 SHA-256，路径相对于 sourceDir
+func (s *SaveService) writeDirectoryToZip(ctx context.Context, zipWriter *zip.Writer, opts zipOptions, sourceDir, dirName string, progress progressReporter) ([]ManifestFile, error) {
+	var files []ManifestFile
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		relPath, err := filepath.Rel(sourceDir, path)
 		if err != nil {
@@ -364,11 +402,11 @@ func (s *SaveService) addDirectoryToZip(zipWriter *zip.Writer, sourceDir, dirNam
 
 		if info.IsDir() {
 			zipPath += "/"
-			_, err := zipWriter.Create(zipPath)
+			_, err := zipWriter.CreateHeader(opts.dirHeader(zipPath, info.ModTime()))
 			return err
 		}
 
-		writer, err := zipWriter.Create(zipPath)
+		writer, err := zipWriter.CreateHeader(opts.fileHeader(zipPath, info.ModTime()))
 		if err != nil {
 			return err
 		}
@@ -379,132 +417,226 @@ func (s *SaveService) addDirectoryToZip(zipWriter *zip.Writer, sourceDir, dirNam
 		}
 		defer file.Close()
 
-		_, err = io.Copy(writer, file)
-		return err
+		hash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(writer, hash), file)
+		if err != nil {
+			return err
+		}
+		files = append(files, ManifestFile{
+			Path:   strings.ReplaceAll(relPath, "\\", "/"),
+			Size:   n,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+		progress.advance(1, n)
+		return nil
 	})
+	return files, err
 }
 
-// extractAndImportSave 解压并导入存档
-func (s *SaveService) extractAndImportSave(zipPath string, overwrite, backup bool) (interface{}, error) {
-	// 打开ZIP文件
-	reader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("打开ZIP文件失败: %v", err)
+// directoryStats 统计目录中的文件数量和总大小，用于计算进度
+func directoryStats(dir string) (int, int64) {
+	files := 0
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// isValidSaveName 验证存档名称是否可以作为目录名使用
+func isValidSaveName(name string) bool {
+	if name == "" || len(name) > 100 {
+		return false
+	}
+	if name == "." || name == ".." || strings.HasPrefix(name, ".") {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\:*?"<>|`)
+}
+
+// errSaveExists 目标位置已经有同名的存档
+var errSaveExists = errors.New("存档已存在")
+
+// renameSaveDirectory 重命名存档目录及其主文件，失败时回滚已完成的步骤
+func (s *SaveService) renameSaveDirectory(savePath, newName, farmName string) (string, error) {
+	parentDir := filepath.Dir(savePath)
+	newPath := filepath.Join(parentDir, newName)
+
+	if _, err := os.Lstat(newPath); err == nil {
+		return "", fmt.Errorf("%w: %s", errSaveExists, newName)
+	}
+
+	mainFile := s.findMainSaveFile(savePath)
+	if mainFile == "" {
+		return "", fmt.Errorf("未找到有效的存档文件")
 	}
-	defer reader.Close()
 
-	// 检查ZIP内容，找到存档目录
-	var saveDir string
-	var rootFiles []string
+	// 记录已完成的操作，以便出错时按相反顺序撤销
+	var undo []func() error
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
 
-	for _, file := range reader.File {
-		if file.FileInfo().IsDir() {
+	// 主文件和 _old 文件必须与目录同名，游戏才能识别
+	renames := [][2]string{
+		{mainFile, filepath.Join(savePath, newName)},
+		{mainFile + "_old", filepath.Join(savePath, newName+"_old")},
+	}
+	for _, r := range renames {
+		from, to := r[0], r[1]
+		if from == to {
+			continue
+		}
+		if _, err := os.Stat(from); err != nil {
 			continue
 		}
+		if err := os.Rename(from, to); err != nil {
+			rollback()
+			return "", fmt.Errorf("重命名文件失败: %v", err)
+		}
+		undo = append(undo, func() error { return os.Rename(to, from) })
+	}
+
+	// 可选更新农场名称，_old 文件也要更新，否则游戏回退到 _old 时会显示原来的名称
+	if farmName != "" {
+		files := []string{
+			filepath.Join(savePath, newName),
+			filepath.Join(savePath, newName+"_old"),
+			filepath.Join(savePath, "SaveGameInfo"),
+			filepath.Join(savePath, "SaveGameInfo_old"),
+		}
+		for _, file := range files {
+			original, err := os.ReadFile(file)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				rollback()
+				return "", fmt.Errorf("读取存档文件失败: %v", err)
+			}
 
-		dir := filepath.Dir(file.Name)
-		if dir == "." {
-			rootFiles = append(rootFiles, file.Name)
-		} else {
-			if saveDir == "" {
-				saveDir = strings.Split(dir, "/")[0]
+			// 联机存档中每个农场帮手都有自己的 farmName，全部替换
+			updated, ok := replaceXMLElementText(original, "farmName", farmName)
+			if !ok {
+				continue
+			}
+			if err := writeFileAtomic(file, updated); err != nil {
+				rollback()
+				return "", fmt.Errorf("更新农场名称失败: %v", err)
 			}
+			file := file
+			undo = append(undo, func() error { return writeFileAtomic(file, original) })
 		}
 	}
 
-	// 确定目标目录名
-	var targetName string
-	if saveDir != "" {
-		targetName = saveDir
-	} else {
-		// 如果文件在根目录，从文件名推断存档名
-		if len(rootFiles) > 0 {
-			baseName := filepath.Base(zipPath)
-			targetName = strings.TrimSuffix(baseName, filepath.Ext(baseName))
-		} else {
-			return nil, fmt.Errorf("无效的存档文件结构")
+	// 最后重命名目录本身。开头的检查之后目标仍可能被创建，os.Rename 会替换已存在的空目录
+	if err := renameNoReplace(savePath, newPath); err != nil {
+		rollback()
+		if errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("%w: %s", errSaveExists, newName)
 		}
+		return "", fmt.Errorf("重命名目录失败: %v", err)
 	}
 
-	targetPath := filepath.Join(s.currentPath, targetName)
+	return newPath, nil
+}
 
-	// 检查是否存在同名存档
-	if _, err := os.Stat(targetPath); err == nil {
-		if !overwrite {
-			return nil, fmt.Errorf("存档已存在: %s", targetName)
+// replaceXMLElementText 替换所有指定元素的文本内容，空的自闭合元素（如 <farmName />）同样会被替换。
+// 返回是否找到了该元素
+func replaceXMLElementText(data []byte, element, value string) ([]byte, bool) {
+	openTag := []byte("<" + element + ">")
+	closeTag := []byte("</" + element + ">")
+	emptyTags := [][]byte{[]byte("<" + element + " />"), []byte("<" + element + "/>")}
+
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(value))
+	replacement := append(append(append([]byte{}, openTag...), escaped.Bytes()...), closeTag...)
+
+	var result bytes.Buffer
+	found := false
+	for len(data) > 0 {
+		// 找到下一个开始标签或自闭合标签
+		start, length, empty := -1, 0, false
+		if i := bytes.Index(data, openTag); i >= 0 {
+			start, length = i, len(openTag)
 		}
-
-		// 备份现有存档
-		if backup {
-			backupPath := filepath.Join("./backups", fmt.Sprintf("%s_backup_%d.zip", targetName, time.Now().Unix()))
-			if err := s.createBackup(targetPath, backupPath); err != nil {
-				return nil, fmt.Errorf("备份现有存档失败: %v", err)
+		for _, tag := range emptyTags {
+			if i := bytes.Index(data, tag); i >= 0 && (start < 0 || i < start) {
+				start, length, empty = i, len(tag), true
 			}
 		}
+		if start < 0 {
+			break
+		}
 
-		// 删除现有存档
-		if err := os.RemoveAll(targetPath); err != nil {
-			return nil, fmt.Errorf("删除现有存档失败: %v", err)
+		end := start + length
+		if !empty {
+			closeIndex := bytes.Index(data[end:], closeTag)
+			if closeIndex < 0 {
+				break
+			}
+			end += closeIndex + len(closeTag)
 		}
-	}
 
-	// 创建目标目录
-	if err := os.MkdirAll(targetPath, 0755); err != nil {
-		return nil, fmt.Errorf("创建目标目录失败: %v", err)
+		result.Write(data[:start])
+		result.Write(replacement)
+		data = data[end:]
+		found = true
 	}
-
-	// 解压文件
-	for _, file := range reader.File {
-		if err := s.extractFile(file, targetPath, saveDir); err != nil {
-			return nil, fmt.Errorf("解压文件失败: %v", err)
-		}
+	if !found {
+		return data, false
 	}
+	result.Write(data)
+	return result.Bytes(), true
+}
 
-	// 返回导入结果
-	return gin.H{
-		"name":      targetName,
-		"path":      targetPath,
-		"overwrite": overwrite,
-		"backup":    backup,
-	}, nil
+// renameIfAbsent 目标不存在时才重命名。检查和重命名之间目标仍可能被创建，只在无法原子完成时使用
+func renameIfAbsent(oldPath, newPath string) error {
+	if _, err := os.Lstat(newPath); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrExist}
+	}
+	return os.Rename(oldPath, newPath)
 }
 
-// extractFile 解压单个文件
-func (s *SaveService) extractFile(file *zip.File, targetPath, saveDir string) error {
-	reader, err := file.Open()
-	if err != nil {
+// writeFileAtomic 先写入临时文件再重命名，避免写到一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
 		return err
 	}
-	defer reader.Close()
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
 
-	// 计算目标路径
-	var filePath string
-	if saveDir != "" && strings.HasPrefix(file.Name, saveDir+"/") {
-		// 去掉顶级目录前缀
-		relativePath := strings.TrimPrefix(file.Name, saveDir+"/")
-		filePath = filepath.Join(targetPath, relativePath)
-	} else {
-		filePath = filepath.Join(targetPath, file.Name)
+// checkSaveConflict 按导入的冲突规则处理目标位置的同名存档：
+// 不允许覆盖时返回错误，需要备份时先备份现有存档
+func (s *SaveService) checkSaveConflict(targetPath string, overwrite, backup bool) (bool, string, error) {
+	if _, err := os.Stat(targetPath); err != nil {
+		return false, "", nil
 	}
 
-	// 确保目录存在
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return err
+	targetName := filepath.Base(targetPath)
+	if !overwrite {
+		return true, "", fmt.Errorf("存档已存在: %s", targetName)
 	}
 
-	// 跳过目录
-	if file.FileInfo().IsDir() {
-		return nil
+	if !backup {
+		return true, "", nil
 	}
 
-	// 创建文件
-	writer, err := os.Create(filePath)
+	backupPath, err := s.createBackup(targetPath, fmt.Sprintf("%s_backup_%d.zip", targetName, time.Now().Unix()))
 	if err != nil {
-		return err
+		return true, "", fmt.Errorf("备份现有存档失败: %v", err)
 	}
-	defer writer.Close()
-
-	// 复制内容
-	_, err = io.Copy(writer, reader)
-	return err
+	return true, backupPath, nil
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceXMLElementText(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		want      string
+		wantFound bool
+	}{
+		{
+			name:      "single element",
+			data:      `<player><name>Alice</name><farmName>Old</farmName></player>`,
+			want:      `<player><name>Alice</name><farmName>New &amp; Co</farmName></player>`,
+			wantFound: true,
+		},
+		{
+			name:      "every farmhand",
+			data:      `<player><farmName>Old</farmName></player><farmhands><Farmer><farmName>Old</farmName></Farmer><Farmer><farmName>Other</farmName></Farmer></farmhands>`,
+			want:      `<player><farmName>New &amp; Co</farmName></player><farmhands><Farmer><farmName>New &amp; Co</farmName></Farmer><Farmer><farmName>New &amp; Co</farmName></Farmer></farmhands>`,
+			wantFound: true,
+		},
+		{
+			name:      "self-closing with space",
+			data:      `<Farmer><farmName /><money>0</money></Farmer>`,
+			want:      `<Farmer><farmName>New &amp; Co</farmName><money>0</money></Farmer>`,
+			wantFound: true,
+		},
+		{
+			name:      "self-closing without space",
+			data:      `<Farmer><farmName/></Farmer>`,
+			want:      `<Farmer><farmName>New &amp; Co</farmName></Farmer>`,
+			wantFound: true,
+		},
+		{
+			name:      "mixed shapes",
+			data:      `<a><farmName /></a><b><farmName>Old</farmName></b><c><farmName/></c>`,
+			want:      `<a><farmName>New &amp; Co</farmName></a><b><farmName>New &amp; Co</farmName></b><c><farmName>New &amp; Co</farmName></c>`,
+			wantFound: true,
+		},
+		{
+			name:      "similar element names are kept",
+			data:      `<farmNameSuffix>x</farmNameSuffix><farmName>Old</farmName>`,
+			want:      `<farmNameSuffix>x</farmNameSuffix><farmName>New &amp; Co</farmName>`,
+			wantFound: true,
+		},
+		{
+			name: "missing element",
+			data: `<player><name>Alice</name></player>`,
+			want: `<player><name>Alice</name></player>`,
+		},
+		{
+			name: "unclosed element",
+			data: `<player><farmName>Old</player>`,
+			want: `<player><farmName>Old</player>`,
+		},
+	}
+	for _, tt := range tests {
+		got, found := replaceXMLElementText([]byte(tt.data), "farmName", "New & Co")
+		if found != tt.wantFound || string(got) != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.name, got, found, tt.want, tt.wantFound)
+		}
+	}
+}
+
+// writeRenameTestSave 创建带 _old 文件和 SaveGameInfo 的存档，所有文件的农场名称都是 Old
+func writeRenameTestSave(t *testing.T, root, name string) string {
+	t.Helper()
+	save := `<SaveGame><player><name>Alice</name><farmName>Old</farmName></player><farmhands><Farmer><farmName /></Farmer></farmhands></SaveGame>`
+	info := `<Farmer><name>Alice</name><farmName>Old</farmName></Farmer>`
+	savePath := filepath.Join(root, name)
+	if err := os.MkdirAll(savePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{name: save, name + "_old": save, "SaveGameInfo": info, "SaveGameInfo_old": info}
+	for file, data := range files {
+		if err := os.WriteFile(filepath.Join(savePath, file), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return savePath
+}
+
+func TestRenameSaveDirectory(t *testing.T) {
+	root := t.TempDir()
+	savePath := writeRenameTestSave(t, root, "Farm_1")
+	s := &SaveService{}
+
+	newPath, err := s.renameSaveDirectory(savePath, "Farm_2", "New")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPath != filepath.Join(root, "Farm_2") {
+		t.Errorf("new path = %s", newPath)
+	}
+	if _, err := os.Stat(savePath); !os.IsNotExist(err) {
+		t.Errorf("old directory still exists: %v", err)
+	}
+	for _, file := range []string{"Farm_2", "Farm_2_old", "SaveGameInfo", "SaveGameInfo_old"} {
+		data, err := os.ReadFile(filepath.Join(newPath, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "Old") || strings.Contains(string(data), "<farmName />") {
+			t.Errorf("%s still has the old farm name: %s", file, data)
+		}
+	}
+}
+
+func TestRenameSaveDirectoryConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		create func(t *testing.T, path string)
+	}{
+		{"existing save", func(t *testing.T, path string) { writeRenameTestSave(t, filepath.Dir(path), filepath.Base(path)) }},
+		{"existing empty directory", func(t *testing.T, path string) { os.Mkdir(path, 0755) }},
+		{"existing file", func(t *testing.T, path string) { os.WriteFile(path, nil, 0644) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			savePath := writeRenameTestSave(t, root, "Farm_1")
+			target := filepath.Join(root, "Farm_2")
+			tt.create(t, target)
+			before, _ := os.ReadDir(target)
+
+			_, err := (&SaveService{}).renameSaveDirectory(savePath, "Farm_2", "New")
+			if !errors.Is(err, errSaveExists) {
+				t.Fatalf("err = %v, want errSaveExists", err)
+			}
+			if after, _ := os.ReadDir(target); len(after) != len(before) {
+				t.Errorf("target changed: %d entries, had %d", len(after), len(before))
+			}
+			if _, err := os.Stat(filepath.Join(savePath, "Farm_1")); err != nil {
+				t.Errorf("source was modified: %v", err)
+			}
+		})
+	}
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "Farm_1")
+	if err := os.Mkdir(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// os.Rename 会用目录替换已存在的空目录，renameNoReplace 不会
+	target := filepath.Join(root, "Farm_2")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := renameNoReplace(source, target); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("rename onto an empty directory = %v, want os.ErrExist", err)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Fatalf("source moved: %v", err)
+	}
+
+	if err := renameNoReplace(source, filepath.Join(root, "Farm_3")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "Farm_3")); err != nil {
+		t.Errorf("rename to a free name: %v", err)
+	}
+}
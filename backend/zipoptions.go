@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出和备份支持的压缩级别
+const (
+	zipCompressionStore   = "store"
+	zipCompressionFast    = "fast"
+	zipCompressionDefault = "default"
+	zipCompressionBest    = "best"
+)
+
+// deterministicModTime 确定性压缩包中所有条目使用的修改时间（ZIP 格式能表示的最早时间）
+var deterministicModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// zipOptions 生成导出和备份压缩包时使用的选项
+type zipOptions struct {
+	compression   string
+	deterministic bool
+}
+
+// zipOptions 返回压缩选项：环境变量 SAVE_ZIP_COMPRESSION、SAVE_ZIP_DETERMINISTIC > 配置文件 > 默认值
+func (s *SaveService) zipOptions() zipOptions {
+	s.mu.RLock()
+	opts := zipOptions{
+		compression:   s.configZipCompression,
+		deterministic: s.configZipDeterministic,
+	}
+	s.mu.RUnlock()
+
+	if value := os.Getenv("SAVE_ZIP_COMPRESSION"); value != "" {
+		opts.compression = value
+	}
+	opts.compression = strings.ToLower(opts.compression)
+	switch opts.compression {
+	case zipCompressionStore, zipCompressionFast, zipCompressionDefault, zipCompressionBest:
+	case "":
+		opts.compression = zipCompressionDefault
+	default:
+		log.Printf("无效的压缩级别: %s，使用默认级别", opts.compression)
+		opts.compression = zipCompressionDefault
+	}
+
+	if value := os.Getenv("SAVE_ZIP_DETERMINISTIC"); value != "" {
+		if deterministic, err := strconv.ParseBool(value); err == nil {
+			opts.deterministic = deterministic
+		} else {
+			log.Printf("无效的 SAVE_ZIP_DETERMINISTIC: %s", value)
+		}
+	}
+	return opts
+}
+
+// newZipWriter 按压缩级别创建 ZIP Writer
+func (o zipOptions) newZipWriter(w io.Writer) *zip.Writer {
+	zipWriter := zip.NewWriter(w)
+
+	level := flate.DefaultCompression
+	switch o.compression {
+	case zipCompressionFast:
+		level = flate.BestSpeed
+	case zipCompressionBest:
+		level = flate.BestCompression
+	}
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+	return zipWriter
+}
+
+// fileHeader 生成文件条目的头信息。确定性模式下使用固定的修改时间，否则使用文件的修改时间
+func (o zipOptions) fileHeader(name string, modTime time.Time) *zip.FileHeader {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	if o.compression == zipCompressionStore {
+		header.Method = zip.Store
+	}
+	if o.deterministic || modTime.IsZero() {
+		modTime = deterministicModTime
+	}
+	header.Modified = modTime
+	return header
+}
+
+// dirHeader 生成目录条目的头信息
+func (o zipOptions) dirHeader(name string, modTime time.Time) *zip.FileHeader {
+	if o.deterministic || modTime.IsZero() {
+		modTime = deterministicModTime
+	}
+	return &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: modTime,
+	}
+}
@@ -251,7 +251,6 @@ create_project_dir() {
     mkdir -p $PROJECT_DIR/logs
     mkdir -p $PROJECT_DIR/data
     mkdir -p $PROJECT_DIR/backups
-    mkdir -p $PROJECT_DIR/downloads
     mkdir -p $PROJECT_DIR/bin
     
     # 设置权限
@@ -381,12 +380,6 @@ server {
         proxy_read_timeout 300;
     }
     
-    # 下载文件
-    location /downloads/ {
-        alias $PROJECT_DIR/backend/downloads/;
-        autoindex off;
-    }
-    
     # 安全头
     add_header X-Frame-Options "SAMEORIGIN" always;
     add_header X-XSS-Protection "1; mode=block" always;
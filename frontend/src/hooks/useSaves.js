@@ -1,6 +1,9 @@
 import { useState, useEffect, useCallback } from 'react'
 import { saveAPI } from '../utils/api.js'
 
+// 批量导出达到这个数量时改用签名下载链接
+const LINK_EXPORT_THRESHOLD = 5
+
 export const useSaves = () => {
   const [saves, setSaves] = useState([])
   const [loading, setLoading] = useState(false)
@@ -27,9 +30,10 @@ export const useSaves = () => {
   }, [])
 
   // 删除存档
-  const deleteSave = useCallback(async (id) => {
+  // 备份失败时不会删除，force 为 true 时仍然删除
+  const deleteSave = useCallback(async (id, force = false) => {
     try {
-      const response = await saveAPI.deleteSave(id)
+      const response = await saveAPI.deleteSave(id, force)
       if (response.success) {
         setSaves(prev => prev.filter(save => save.id !== id))
         setSelectedSaves(prev => prev.filter(saveId => saveId !== id))
@@ -43,22 +47,23 @@ export const useSaves = () => {
   }, [])
 
   // 批量删除存档
-  const batchDeleteSaves = useCallback(async (saveIds = selectedSaves) => {
+  // 备份失败的存档不会被删除，force 为 true 时仍然删除
+  const batchDeleteSaves = useCallback(async (saveIds = selectedSaves, force = false) => {
     if (saveIds.length === 0) {
       return { success: false, error: '请选择要删除的存档' }
     }
 
     try {
-      const response = await saveAPI.batchDelete(saveIds)
-      if (response.success) {
-        setSaves(prev => prev.filter(save => !saveIds.includes(save.id)))
-        setSelectedSaves([])
-        return { success: true, message: response.message }
-      } else {
-        return { success: false, error: response.error }
-      }
+      const response = await saveAPI.batchDelete(saveIds, force)
+      // 只移除实际删除成功的存档，失败的保持选中
+      const deleted = (response.data?.results || [])
+        .filter(item => item.status === 'deleted')
+        .map(item => item.id)
+      setSaves(prev => prev.filter(save => !deleted.includes(save.id)))
+      setSelectedSaves(prev => prev.filter(id => !deleted.includes(id)))
+      return { success: true, message: response.message, results: response.data?.results }
     } catch (err) {
-      return { success: false, error: err.error || '批量删除失败' }
+      return { success: false, error: err.error || '批量删除失败', results: err.data?.results }
     }
   }, [selectedSaves])
 
@@ -92,6 +97,16 @@ export const useSaves = () => {
     }
 
     try {
+      // 存档较多时使用签名下载链接，由浏览器直接下载，不必把整个压缩包读入内存
+      if (saveIds.length >= LINK_EXPORT_THRESHOLD) {
+        const response = await saveAPI.batchExportLink(saveIds)
+        if (!response.success) {
+          return { success: false, error: response.error }
+        }
+        window.location.href = response.data.url
+        return { success: true, message: '批量导出已开始下载' }
+      }
+
       const response = await saveAPI.batchExport(saveIds)
       const filename = `stardew_saves_batch_${new Date().getTime()}.zip`
       
@@ -112,9 +127,18 @@ export const useSaves = () => {
   }, [selectedSaves])
 
   // 导入存档
+  // file 可以是单个压缩包(zip/tar/tar.gz)，也可以是选择文件夹得到的文件列表
   const importSave = useCallback(async (file, options = {}) => {
     const formData = new FormData()
-    formData.append('file', file)
+    if (file instanceof FileList || Array.isArray(file)) {
+      // 浏览器上传时会丢掉目录结构，单独提交每个文件的相对路径
+      Array.from(file).forEach(f => {
+        formData.append('file', f)
+        formData.append('paths', f.webkitRelativePath || f.name)
+      })
+    } else {
+      formData.append('file', file)
+    }
     
     if (options.overwriteExisting !== undefined) {
       formData.append('overwriteExisting', options.overwriteExisting.toString())
@@ -122,6 +146,9 @@ export const useSaves = () => {
     if (options.backupExisting !== undefined) {
       formData.append('backupExisting', options.backupExisting.toString())
     }
+    if (options.overwriteSaves?.length) {
+      formData.append('overwriteSaves', options.overwriteSaves.join(','))
+    }
 
     try {
       const response = await saveAPI.importSave(formData)
@@ -30,14 +30,27 @@ api.interceptors.response.use(
 export const pathAPI = {
   getCurrentPath: () => api.get('/current-path'),
   setPath: (path) => api.post('/set-path', { path }),
-  validatePath: (path) => api.get('/validate-path', { params: { path } })
+  validatePath: (path) => api.get('/validate-path', { params: { path } }),
+  browse: (path) => api.get('/browse', { params: { path } })
+}
+
+// 存档库API
+export const libraryAPI = {
+  getLibraries: () => api.get('/libraries'),
+  saveLibrary: (library) => api.post('/libraries', library),
+  deleteLibrary: (name) => api.delete(`/libraries/${name}`),
+  setDefault: (name) => api.post(`/libraries/${name}/default`)
 }
 
 // 存档管理API
 export const saveAPI = {
   getSaves: () => api.get('/saves'),
   getSaveDetails: (id) => api.get(`/saves/${id}`),
-  deleteSave: (id) => api.delete(`/saves/${id}`),
+  deleteSave: (id, force = false) => api.delete(`/saves/${id}`, { params: force ? { force: true } : {} }),
+  renameSave: (id, newName, farmName) => api.post(`/saves/${id}/rename`, { newName, farmName }),
+  transferSave: (id, options) => api.post(`/saves/${id}/transfer`, options),
+  getInventory: (id) => api.get(`/saves/${id}/inventory`),
+  searchItems: (q, library) => api.get('/items/search', { params: library ? { q, library } : { q } }),
   importSave: (formData) => {
     return axios.post(`${API_BASE}/saves/import`, formData, {
       headers: {
@@ -46,17 +59,96 @@ export const saveAPI = {
       timeout: 60000
     }).then(response => response.data)
   },
-  exportSave: (id) => {
+  // 比较两个存档版本，base/target 为 { source, library, id, backup, commit }；
+  // 上传的版本需要传 FormData，包含 base、target（JSON 字符串）和 baseFile、targetFile
+  compareSaves: (request) => {
+    if (request instanceof FormData) {
+      return axios.post(`${API_BASE}/saves/compare`, request, {
+        headers: {
+          'Content-Type': 'multipart/form-data'
+        },
+        timeout: 60000
+      }).then(response => response.data)
+    }
+    return api.post('/saves/compare', request)
+  },
+  previewImport: (formData) => {
+    return axios.post(`${API_BASE}/saves/import/preview`, formData, {
+      headers: {
+        'Content-Type': 'multipart/form-data'
+      },
+      timeout: 60000
+    }).then(response => response.data)
+  },
+  // 分块上传：init 创建会话，uploadChunk 从 offset 写入分块，complete 校验并导入
+  initUpload: (filename, size, sha256) => api.post('/uploads', { filename, size, sha256 }),
+  getUpload: (id) => api.get(`/uploads/${id}`),
+  uploadChunk: (id, offset, chunk) => {
+    return axios.put(`${API_BASE}/uploads/${id}`, chunk, {
+      params: { offset },
+      headers: {
+        'Content-Type': 'application/octet-stream'
+      },
+      timeout: 120000
+    }).then(response => response.data)
+  },
+  completeUpload: (id, options = {}) => api.post(`/uploads/${id}/complete`, options),
+  cancelUpload: (id) => api.delete(`/uploads/${id}`),
+  importLocal: (path, options = {}) => api.post('/saves/import/local', { path, ...options }),
+  getInbox: () => api.get('/inbox'),
+  // encrypt 为 true 时导出加密文件（.enc），需要服务器配置加密密钥
+  exportSave: (id, encrypt = false) => {
     return axios.get(`${API_BASE}/saves/${id}/export`, {
+      params: encrypt ? { encrypt: true } : {},
       responseType: 'blob'
     })
   },
-  batchExport: (saveIds) => {
+  batchExport: (saveIds, encrypt = false) => {
     return axios.post(`${API_BASE}/saves/batch-export`, { saveIds }, {
+      params: encrypt ? { encrypt: true } : {},
       responseType: 'blob'
     })
   },
-  batchDelete: (saveIds) => api.delete('/saves/batch-delete', { data: { saveIds } })
+  batchExportLink: (saveIds, encrypt = false) => api.post('/saves/batch-export', { saveIds }, {
+    params: encrypt ? { mode: 'link', encrypt: true } : { mode: 'link' }
+  }),
+  batchDelete: (saveIds, force = false) => api.delete('/saves/batch-delete', { data: { saveIds, force } })
+}
+
+// 后台任务API：带 async 参数的导入、导出和删除会返回任务，通过这里查询进度和结果
+export const jobAPI = {
+  getJobs: () => api.get('/jobs'),
+  getJob: (id) => api.get(`/jobs/${id}`),
+  cancelJob: (id) => api.post(`/jobs/${id}/cancel`),
+  downloadArtifact: (id) => {
+    return axios.get(`${API_BASE}/jobs/${id}/download`, {
+      responseType: 'blob'
+    })
+  }
+}
+
+// 备份API
+export const backupAPI = {
+  getBackups: (target) => api.get('/backups', { params: target ? { target } : {} }),
+  createLibraryBackup: () => api.post('/backups'),
+  restoreBackup: (name, options = {}, target) => api.post(`/backups/${encodeURIComponent(name)}/restore`, options, {
+    params: target ? { target } : {}
+  }),
+  replicateBackup: (name, target) => api.post(`/backups/${encodeURIComponent(name)}/replicate`, null, {
+    params: target ? { target } : {}
+  }),
+  getBackupTargets: () => api.get('/backup-targets')
+}
+
+// 存档历史API
+export const historyAPI = {
+  getHistorySaves: () => api.get('/history'),
+  getSaveHistory: (saveId, limit) => api.get(`/saves/${saveId}/history`, { params: limit ? { limit } : {} }),
+  createSnapshot: (saveId, message) => api.post(`/saves/${saveId}/history`, { message }),
+  getDiff: (saveId, { from, to, word } = {}) => api.get(`/saves/${saveId}/history/diff`, {
+    params: { from, to, word }
+  }),
+  restore: (saveId, commit) => api.post(`/saves/${saveId}/history/restore`, { commit })
 }
 
 // 日志API
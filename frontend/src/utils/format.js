@@ -109,7 +109,8 @@ export const copyToClipboard = async (text) => {
 
 // 验证文件类型
 export const validateFileType = (file, allowedTypes = ['application/zip', 'application/x-zip-compressed']) => {
-  return allowedTypes.includes(file.type) || file.name.toLowerCase().endsWith('.zip')
+  const name = file.name.toLowerCase()
+  return allowedTypes.includes(file.type) || ['.zip', '.tar', '.tar.gz', '.tgz'].some(ext => name.endsWith(ext))
 }
 
 // 验证文件大小
@@ -18,7 +18,6 @@ fi
 echo "📁 创建必要的目录..."
 mkdir -p valley_saves
 mkdir -p ../stardew-multiplayer-docker/valley_saves
-mkdir -p backend/downloads
 mkdir -p backend/backups
 mkdir -p backend/temp
 